@@ -0,0 +1,455 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Access requests are ConfigMaps labeled with accessRequestLabel, whose annotations hold the state of the request.
+// An approval is a copy of the request labeled with accessApprovalLabel, which the approver creates in the
+// approval namespace of the profile. The requester can change the request, but not the approval.
+const (
+	accessRequestLabel   = "podsql/access-request"
+	accessRequestPrefix  = "podsql-access-"
+	accessApprovalLabel  = "podsql/access-approval"
+	accessApprovalPrefix = "podsql-approval-"
+	requesterAnnotation  = "podsql/requester"
+	profileAnnotation    = "podsql/profile"
+	engineAnnotation     = "podsql/engine"
+	hostAnnotation       = "podsql/host"
+	databaseAnnotation   = "podsql/database"
+	reasonAnnotation     = "podsql/reason"
+	expiresAtAnnotation  = "podsql/expires-at"
+	approvedByAnnotation = "podsql/approved-by"
+	approvedAtAnnotation = "podsql/approved-at"
+	usedByAnnotation     = "podsql/used-by"
+)
+
+const defaultAccessRequestTTL = time.Hour
+
+// accessRequest asks for a query, or an interactive session if Query is empty, to be run on a profile that requires approval.
+type accessRequest struct {
+	ID         string
+	Requester  string
+	Profile    string
+	Engine     string
+	Host       string
+	Database   string
+	Query      string
+	Reason     string
+	ExpiresAt  time.Time
+	ApprovedBy string
+	UsedBy     string
+}
+
+func accessRequestFromConfigMap(cm *corev1.ConfigMap) accessRequest {
+	a := cm.Annotations
+	expiresAt, _ := time.Parse(time.RFC3339, a[expiresAtAnnotation])
+	return accessRequest{
+		ID:         strings.TrimPrefix(strings.TrimPrefix(cm.Name, accessRequestPrefix), accessApprovalPrefix),
+		Requester:  a[requesterAnnotation],
+		Profile:    a[profileAnnotation],
+		Engine:     a[engineAnnotation],
+		Host:       a[hostAnnotation],
+		Database:   a[databaseAnnotation],
+		Query:      cm.Data["query"],
+		Reason:     a[reasonAnnotation],
+		ExpiresAt:  expiresAt,
+		ApprovedBy: a[approvedByAnnotation],
+		UsedBy:     a[usedByAnnotation],
+	}
+}
+
+func (r accessRequest) configMap(namespace string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      accessRequestPrefix + r.ID,
+			Namespace: namespace,
+			Labels:    map[string]string{accessRequestLabel: "true"},
+			Annotations: map[string]string{
+				requesterAnnotation: r.Requester,
+				profileAnnotation:   r.Profile,
+				engineAnnotation:    r.Engine,
+				hostAnnotation:      r.Host,
+				databaseAnnotation:  r.Database,
+				reasonAnnotation:    r.Reason,
+				expiresAtAnnotation: r.ExpiresAt.UTC().Format(time.RFC3339),
+			},
+		},
+		Data: map[string]string{"query": r.Query},
+	}
+}
+
+// approvalConfigMap is the approval of the request by r.ApprovedBy.
+func (r accessRequest) approvalConfigMap(namespace string, now time.Time) *corev1.ConfigMap {
+	cm := r.configMap(namespace)
+	cm.Name = accessApprovalPrefix + r.ID
+	cm.Labels = map[string]string{accessApprovalLabel: "true"}
+	cm.Annotations[approvedByAnnotation] = r.ApprovedBy
+	cm.Annotations[approvedAtAnnotation] = now.UTC().Format(time.RFC3339)
+	return cm
+}
+
+func (r accessRequest) summary() string {
+	if r.Query == "" {
+		return "(interactive session)"
+	}
+	return r.Query
+}
+
+// matches reports whether the request was made for the session. A request without a database only matches
+// a session without one, so that it does not open every database of the host.
+func (r accessRequest) matches(conf *Config, dbCommander DBCommander) bool {
+	connectInfo := dbCommander.ConnectInfo()
+	query := ""
+	if !dbCommander.IsInteractive() {
+		query = strings.TrimSpace(dbCommander.Query())
+	}
+	return r.Profile == conf.ProfileName &&
+		r.Engine == dbCommander.CommandType().CommandName() &&
+		r.Host == connectInfo.Server &&
+		r.Database == connectInfo.DbName &&
+		strings.TrimSpace(r.Query) == query
+}
+
+// kubeUser returns the name of the Kubernetes user of the kubeconfig, which identifies requesters and approvers.
+// It can be replaced with a stub in tests.
+var kubeUser = selfSubjectReviewUser
+
+func selfSubjectReviewUser(ctx context.Context, clientset kubernetes.Interface) (string, error) {
+	review, err := clientset.AuthenticationV1().SelfSubjectReviews().Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get the Kubernetes user: %w", err)
+	}
+	if review.Status.UserInfo.Username == "" {
+		return "", fmt.Errorf("failed to get the Kubernetes user: empty username")
+	}
+	return review.Status.UserInfo.Username, nil
+}
+
+// approvalNamespace returns the namespace of the approvals of the profile.
+func approvalNamespace(conf *Config) (string, error) {
+	if conf.Profile.ApprovalNamespace == "" {
+		return "", fmt.Errorf("profile %s requires approval but has no approvalNamespace", conf.ProfileName)
+	}
+	return conf.Profile.ApprovalNamespace, nil
+}
+
+// checkApprovalsReadOnly makes sure that the current user cannot write the approvals of the namespace,
+// because anyone who can create or change an approval can approve their own requests.
+func checkApprovalsReadOnly(ctx context.Context, clientset kubernetes.Interface, namespace string) error {
+	for _, verb := range []string{"create", "update", "patch"} {
+		review, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{Namespace: namespace, Verb: verb, Resource: "configmaps"},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to check the access to the approvals: %w", err)
+		}
+		if review.Status.Allowed {
+			return fmt.Errorf("approvals in namespace %s cannot be trusted because you can %s ConfigMaps there, requesters may only get, list and delete them",
+				namespace, verb)
+		}
+	}
+	return nil
+}
+
+func newAccessRequestID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// createAccessRequest records the request of the current user and returns its ID.
+func createAccessRequest(ctx context.Context, clientset kubernetes.Interface, conf *Config, dbCommander DBCommander, reason string, ttl time.Duration, now time.Time) (string, error) {
+	if _, err := approvalNamespace(conf); err != nil {
+		return "", err
+	}
+	requester, err := kubeUser(ctx, clientset)
+	if err != nil {
+		return "", err
+	}
+	id, err := newAccessRequestID()
+	if err != nil {
+		return "", err
+	}
+	connectInfo := dbCommander.ConnectInfo()
+	if connectInfo.Server == "" {
+		return "", fmt.Errorf("access request requires the host of the database")
+	}
+	r := accessRequest{
+		ID:        id,
+		Requester: requester,
+		Profile:   conf.ProfileName,
+		Engine:    dbCommander.CommandType().CommandName(),
+		Host:      connectInfo.Server,
+		Database:  connectInfo.DbName,
+		Reason:    reason,
+		ExpiresAt: now.Add(ttl),
+	}
+	if !dbCommander.IsInteractive() {
+		r.Query = dbCommander.Query()
+	}
+	if _, err := clientset.CoreV1().ConfigMaps(conf.Namespace).Create(ctx, r.configMap(conf.Namespace), metav1.CreateOptions{}); err != nil {
+		return "", fmt.Errorf("failed to create access request: %w", err)
+	}
+	return id, nil
+}
+
+// approveAccessRequest approves the request as the current user, who must not be the requester, by creating
+// the approval in the approval namespace of the profile.
+func approveAccessRequest(ctx context.Context, clientset kubernetes.Interface, conf *Config, id string, now time.Time) (accessRequest, error) {
+	namespace, err := approvalNamespace(conf)
+	if err != nil {
+		return accessRequest{}, err
+	}
+	approver, err := kubeUser(ctx, clientset)
+	if err != nil {
+		return accessRequest{}, err
+	}
+	cm, err := clientset.CoreV1().ConfigMaps(conf.Namespace).Get(ctx, accessRequestPrefix+id, metav1.GetOptions{})
+	if err != nil {
+		return accessRequest{}, fmt.Errorf("failed to get access request %s: %w", id, err)
+	}
+	if cm.Labels[accessRequestLabel] != "true" {
+		return accessRequest{}, fmt.Errorf("%s is not an access request", cm.Name)
+	}
+	r := accessRequestFromConfigMap(cm)
+	switch {
+	case r.Requester == approver:
+		return r, fmt.Errorf("access request %s must be approved by another user than %s", id, approver)
+	case r.Profile != conf.ProfileName:
+		return r, fmt.Errorf("access request %s is for profile %s, approve it with --profile %s", id, r.Profile, r.Profile)
+	case r.UsedBy != "":
+		return r, fmt.Errorf("access request %s is already used by %s", id, r.UsedBy)
+	case !now.Before(r.ExpiresAt):
+		return r, fmt.Errorf("access request %s has expired", id)
+	}
+	// The requester can change the request until it is approved, so the approver confirms what is copied
+	if err := confirmAccessRequest(r); err != nil {
+		return r, err
+	}
+	r.ApprovedBy = approver
+	if _, err := clientset.CoreV1().ConfigMaps(namespace).Create(ctx, r.approvalConfigMap(namespace, now), metav1.CreateOptions{}); err != nil {
+		return r, fmt.Errorf("failed to approve access request %s: %w", id, err)
+	}
+	return r, nil
+}
+
+// confirmAccessRequest shows the request as it is approved and asks the approver to type its ID.
+func confirmAccessRequest(r accessRequest) error {
+	fmt.Fprintf(confirmOutput, "Access request %s of %s on profile %s\n", r.ID, r.Requester, r.Profile)
+	fmt.Fprintf(confirmOutput, "  engine:   %s\n  host:     %s\n  database: %s\n  expires:  %s\n  reason:   %s\n\n",
+		r.Engine, r.Host, r.Database, r.ExpiresAt.Local().Format(time.DateTime), r.Reason)
+	fmt.Fprintf(confirmOutput, "  %s\n\n", strings.ReplaceAll(r.summary(), "\n", "\n  "))
+	fmt.Fprintf(confirmOutput, "Type %q to approve: ", r.ID)
+
+	answer, err := bufio.NewReader(confirmInput).ReadString('\n')
+	if err != nil && (err != io.EOF || answer == "") {
+		return fmt.Errorf("failed to read the confirmation: %w", err)
+	}
+	if strings.TrimSpace(answer) != r.ID {
+		return fmt.Errorf("confirmation did not match %q, access request %s was not approved", r.ID, r.ID)
+	}
+	return nil
+}
+
+// listAccessRequests returns the requests or the approvals in the namespace, selected by the label, the oldest first.
+func listAccessRequests(ctx context.Context, clientset kubernetes.Interface, namespace, label string) ([]corev1.ConfigMap, error) {
+	list, err := clientset.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{LabelSelector: label + "=true"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list access requests: %w", err)
+	}
+	items := list.Items
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Annotations[expiresAtAnnotation] < items[j].Annotations[expiresAtAnnotation]
+	})
+	return items, nil
+}
+
+// checkApproval refuses to start the session on a profile that requires approval, unless another user
+// approved a request of the current user for it. The approval is used up by the session.
+func checkApproval(ctx context.Context, clientset kubernetes.Interface, conf *Config, podName string, dbCommander DBCommander, now time.Time) error {
+	if !conf.Profile.RequireApproval {
+		return nil
+	}
+	namespace, err := approvalNamespace(conf)
+	if err != nil {
+		return err
+	}
+	if err := checkApprovalsReadOnly(ctx, clientset, namespace); err != nil {
+		return err
+	}
+	requester, err := kubeUser(ctx, clientset)
+	if err != nil {
+		return err
+	}
+	items, err := listAccessRequests(ctx, clientset, namespace, accessApprovalLabel)
+	if err != nil {
+		return err
+	}
+	for _, cm := range items {
+		r := accessRequestFromConfigMap(&cm)
+		if r.Requester != requester || r.ApprovedBy == "" || r.ApprovedBy == requester ||
+			!now.Before(r.ExpiresAt) || !r.matches(conf, dbCommander) {
+			continue
+		}
+		// Only one delete of the approval succeeds, so it is not used by two sessions
+		uid := cm.UID
+		if err := clientset.CoreV1().ConfigMaps(namespace).Delete(ctx, cm.Name, metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &uid}}); err != nil {
+			return fmt.Errorf("failed to use access request %s: %w", r.ID, err)
+		}
+		markAccessRequestUsed(ctx, clientset, conf.Namespace, r.ID, podName)
+		return nil
+	}
+	return fmt.Errorf("profile %s requires an approved access request, create one with podsql --profile %s request-access and ask another user to approve it",
+		conf.ProfileName, conf.ProfileName)
+}
+
+// markAccessRequestUsed records the pod that used the approval in the request, so that it is no longer listed
+// as pending. The request is informational, so failures are only reported as warnings.
+func markAccessRequestUsed(ctx context.Context, clientset kubernetes.Interface, namespace, id, podName string) {
+	configMaps := clientset.CoreV1().ConfigMaps(namespace)
+	cm, err := configMaps.Get(ctx, accessRequestPrefix+id, metav1.GetOptions{})
+	if err == nil {
+		if cm.Annotations == nil {
+			cm.Annotations = map[string]string{}
+		}
+		cm.Annotations[usedByAnnotation] = podName
+		_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: failed to mark access request %s as used: %v\n", id, err)
+	}
+}
+
+func RequestAccessCommands() *cli.Command {
+	return &cli.Command{
+		Name:      "request-access",
+		Usage:     "request approval to run a query or an interactive session on a profile that requires approval",
+		ArgsUsage: "[options] -- <client> <client options>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "reason",
+				Usage:    "why the access is needed, shown to the approver",
+				Required: true,
+			},
+			&cli.DurationFlag{
+				Name:  "ttl",
+				Usage: "how long the request can be approved and used",
+				Value: defaultAccessRequestTTL,
+			},
+		},
+		Action: executeRequestAccessAction,
+	}
+}
+
+func executeRequestAccessAction(c *cli.Context) error {
+	config, err := NewConfig(c)
+	if err != nil {
+		return err
+	}
+	if !config.Profile.RequireApproval {
+		return fmt.Errorf("--profile with requireApproval is required to request access")
+	}
+	args := c.Args().Slice()
+	if len(args) == 0 {
+		return fmt.Errorf("client is required, e.g. podsql --profile %s request-access --reason ... -- psql -h db -c 'SELECT 1'", config.ProfileName)
+	}
+	dbCommander, err := NewDBCommander(args[0], args[1:])
+	if err != nil {
+		return err
+	}
+	// The host and the database of the session may come from the stored credentials
+	if err := resolveCredentials(config, dbCommander); err != nil {
+		return err
+	}
+	clientset, _, err := newClientset()
+	if err != nil {
+		return fmt.Errorf("failed to create clientset: %w", err)
+	}
+	id, err := createAccessRequest(context.Background(), clientset, config, dbCommander, c.String("reason"), c.Duration("ttl"), time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("access request %s is created. ask another user to run: podsql --profile %s -n %s approve %s\n", id, config.ProfileName, config.Namespace, id)
+	return nil
+}
+
+func ApproveCommands() *cli.Command {
+	return &cli.Command{
+		Name:      "approve",
+		Usage:     "approve an access request of another user on the --profile, or list the pending requests without an id",
+		ArgsUsage: "[id]",
+		Action:    executeApproveAction,
+	}
+}
+
+func executeApproveAction(c *cli.Context) error {
+	config, err := NewConfig(c)
+	if err != nil {
+		return err
+	}
+	clientset, _, err := newClientset()
+	if err != nil {
+		return fmt.Errorf("failed to create clientset: %w", err)
+	}
+	ctx := context.Background()
+
+	if c.Args().Len() == 0 {
+		namespace, err := approvalNamespace(config)
+		if err != nil {
+			return err
+		}
+		items, err := listAccessRequests(ctx, clientset, config.Namespace, accessRequestLabel)
+		if err != nil {
+			return err
+		}
+		approvals, err := listAccessRequests(ctx, clientset, namespace, accessApprovalLabel)
+		if err != nil {
+			return err
+		}
+		approved := map[string]bool{}
+		for _, cm := range approvals {
+			approved[accessRequestFromConfigMap(&cm).ID] = true
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tREQUESTER\tPROFILE\tENGINE\tHOST\tDATABASE\tEXPIRES\tREASON\tQUERY")
+		now := time.Now()
+		for _, cm := range items {
+			r := accessRequestFromConfigMap(&cm)
+			if approved[r.ID] || r.UsedBy != "" || r.Profile != config.ProfileName || !now.Before(r.ExpiresAt) {
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.ID, r.Requester, r.Profile, r.Engine, r.Host, r.Database,
+				r.ExpiresAt.Local().Format(time.DateTime), r.Reason, strings.ReplaceAll(r.summary(), "\n", " "))
+		}
+		return w.Flush()
+	}
+
+	r, err := approveAccessRequest(ctx, clientset, config, c.Args().First(), time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("approved access request %s of %s on profile %s: %s\n", r.ID, r.Requester, r.Profile, r.summary())
+	return nil
+}
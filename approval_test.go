@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// approvalTest is a fake cluster with a profile that requires approval. user is the Kubernetes user of the
+// commands, and canWriteApprovals is the answer of the access reviews of the approval namespace.
+type approvalTest struct {
+	clientset         *fake.Clientset
+	conf              *Config
+	user              string
+	canWriteApprovals bool
+	now               time.Time
+}
+
+func newApprovalTest(t *testing.T) *approvalTest {
+	t.Helper()
+	at := &approvalTest{
+		clientset: fake.NewSimpleClientset(),
+		conf: &Config{Namespace: "db", ProfileName: "production",
+			Profile: Profile{RequireApproval: true, ApprovalNamespace: "approvals"}},
+		now: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	at.clientset.PrependReactor("create", "selfsubjectaccessreviews", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &authorizationv1.SelfSubjectAccessReview{Status: authorizationv1.SubjectAccessReviewStatus{Allowed: at.canWriteApprovals}}, nil
+	})
+	orig, origInput, origOutput := kubeUser, confirmInput, confirmOutput
+	kubeUser = func(context.Context, kubernetes.Interface) (string, error) { return at.user, nil }
+	confirmOutput = io.Discard
+	t.Cleanup(func() { kubeUser, confirmInput, confirmOutput = orig, origInput, origOutput })
+	return at
+}
+
+func (at *approvalTest) commander(t *testing.T, query string, args ...string) DBCommander {
+	t.Helper()
+	if len(args) == 0 {
+		args = []string{"-h", "db", "-d", "app"}
+	}
+	m, err := NewPostgresCommander(append(args, "-c", query))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func (at *approvalTest) request(t *testing.T, user, query string) string {
+	t.Helper()
+	at.user = user
+	id, err := createAccessRequest(context.Background(), at.clientset, at.conf, at.commander(t, query), "fix order 123", time.Hour, at.now)
+	if err != nil {
+		t.Fatalf("createAccessRequest() error = %v", err)
+	}
+	return id
+}
+
+// approve approves the request as the user, who types the ID of the request to confirm it.
+func (at *approvalTest) approve(user, id string) error {
+	at.user = user
+	confirmInput = strings.NewReader(id + "\n")
+	_, err := approveAccessRequest(context.Background(), at.clientset, at.conf, id, at.now)
+	return err
+}
+
+func (at *approvalTest) check(t *testing.T, user, query string) error {
+	t.Helper()
+	at.user = user
+	return checkApproval(context.Background(), at.clientset, at.conf, "podsql-1", at.commander(t, query), at.now)
+}
+
+const approvalQuery = "UPDATE orders SET status = 'paid' WHERE id = 123"
+
+func TestAccessRequestApproval(t *testing.T) {
+	at := newApprovalTest(t)
+	id := at.request(t, "alice", approvalQuery)
+
+	if err := at.check(t, "alice", approvalQuery); err == nil {
+		t.Fatal("checkApproval() allowed a request that is not approved")
+	}
+	if err := at.approve("alice", id); err == nil || !strings.Contains(err.Error(), "another user") {
+		t.Fatalf("approveAccessRequest() by the requester error = %v", err)
+	}
+	if err := at.approve("bob", id); err != nil {
+		t.Fatalf("approveAccessRequest() error = %v", err)
+	}
+	if err := at.approve("carol", id); err == nil {
+		t.Fatal("approveAccessRequest() approved a request twice")
+	}
+
+	if err := at.check(t, "alice", "DELETE FROM orders"); err == nil {
+		t.Error("checkApproval() allowed another query than the approved one")
+	}
+	if err := at.check(t, "bob", approvalQuery); err == nil {
+		t.Error("checkApproval() allowed the approver to use the approval")
+	}
+	if err := at.check(t, "alice", approvalQuery); err != nil {
+		t.Fatalf("checkApproval() error = %v", err)
+	}
+	if err := at.check(t, "alice", approvalQuery); err == nil {
+		t.Error("checkApproval() allowed an approval to be used twice")
+	}
+
+	cm, err := at.clientset.CoreV1().ConfigMaps("db").Get(context.Background(), accessRequestPrefix+id, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cm.Annotations[usedByAnnotation] != "podsql-1" {
+		t.Errorf("used-by = %q, want podsql-1", cm.Annotations[usedByAnnotation])
+	}
+	if err := at.approve("carol", id); err == nil {
+		t.Error("approveAccessRequest() approved a used request")
+	}
+}
+
+func TestAccessRequestExpiry(t *testing.T) {
+	at := newApprovalTest(t)
+	expired := at.request(t, "alice", approvalQuery)
+	at.now = at.now.Add(2 * time.Hour)
+	if err := at.approve("bob", expired); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("approveAccessRequest() of an expired request error = %v", err)
+	}
+
+	id := at.request(t, "alice", approvalQuery)
+	if err := at.approve("bob", id); err != nil {
+		t.Fatalf("approveAccessRequest() error = %v", err)
+	}
+	at.now = at.now.Add(2 * time.Hour)
+	if err := at.check(t, "alice", approvalQuery); err == nil {
+		t.Error("checkApproval() allowed an expired approval")
+	}
+}
+
+func TestAccessRequestSelfApproval(t *testing.T) {
+	at := newApprovalTest(t)
+	ctx := context.Background()
+	configMaps := at.clientset.CoreV1().ConfigMaps("db")
+
+	// approving the request with kubectl annotate does not create an approval
+	id := at.request(t, "alice", approvalQuery)
+	cm, err := configMaps.Get(ctx, accessRequestPrefix+id, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm.Annotations[approvedByAnnotation] = "bob"
+	if _, err := configMaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := at.check(t, "alice", approvalQuery); err == nil {
+		t.Error("checkApproval() allowed an approval annotated by the requester")
+	}
+
+	// changing the query of the request after the approval does not change the approved query
+	if err := at.approve("bob", id); err != nil {
+		t.Fatalf("approveAccessRequest() error = %v", err)
+	}
+	cm, err = configMaps.Get(ctx, accessRequestPrefix+id, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm.Data["query"] = "DROP TABLE orders"
+	if _, err := configMaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := at.check(t, "alice", "DROP TABLE orders"); err == nil {
+		t.Error("checkApproval() allowed the query written into the request after the approval")
+	}
+
+	// a requester who can write approvals could have created this one
+	at.canWriteApprovals = true
+	if err := at.check(t, "alice", approvalQuery); err == nil || !strings.Contains(err.Error(), "cannot be trusted") {
+		t.Errorf("checkApproval() with a writable approval namespace error = %v", err)
+	}
+}
+
+func TestCheckApprovalProfiles(t *testing.T) {
+	at := newApprovalTest(t)
+	at.conf.Profile = Profile{}
+	if err := at.check(t, "alice", approvalQuery); err != nil {
+		t.Errorf("checkApproval() without requireApproval error = %v", err)
+	}
+	at.conf.Profile = Profile{RequireApproval: true}
+	if err := at.check(t, "alice", approvalQuery); err == nil || !strings.Contains(err.Error(), "approvalNamespace") {
+		t.Errorf("checkApproval() without approvalNamespace error = %v", err)
+	}
+}
+
+func TestAccessRequestScope(t *testing.T) {
+	at := newApprovalTest(t)
+	at.user = "alice"
+	if _, err := createAccessRequest(context.Background(), at.clientset, at.conf, at.commander(t, approvalQuery, "-d", "app"), "no host", time.Hour, at.now); err == nil {
+		t.Error("createAccessRequest() accepted a request without a host")
+	}
+
+	// a request without a database does not open the databases of the host
+	at.user = "alice"
+	id, err := createAccessRequest(context.Background(), at.clientset, at.conf, at.commander(t, approvalQuery, "-h", "db"), "any database", time.Hour, at.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := at.approve("bob", id); err != nil {
+		t.Fatalf("approveAccessRequest() error = %v", err)
+	}
+	if err := at.check(t, "alice", approvalQuery); err == nil {
+		t.Error("checkApproval() allowed a database the request did not name")
+	}
+	at.user = "alice"
+	if err := checkApproval(context.Background(), at.clientset, at.conf, "podsql-1", at.commander(t, approvalQuery, "-h", "other"), at.now); err == nil {
+		t.Error("checkApproval() allowed another host")
+	}
+}
+
+func TestApproveConfirmation(t *testing.T) {
+	at := newApprovalTest(t)
+	id := at.request(t, "alice", approvalQuery)
+
+	var out strings.Builder
+	confirmOutput = &out
+	at.user = "bob"
+	confirmInput = strings.NewReader("yes\n")
+	if _, err := approveAccessRequest(context.Background(), at.clientset, at.conf, id, at.now); err == nil {
+		t.Fatal("approveAccessRequest() approved without typing the ID")
+	}
+	if !strings.Contains(out.String(), approvalQuery) || !strings.Contains(out.String(), "fix order 123") {
+		t.Errorf("the confirmation does not show the request:\n%s", out.String())
+	}
+	if err := at.check(t, "alice", approvalQuery); err == nil {
+		t.Error("checkApproval() allowed a request whose approval was not confirmed")
+	}
+}
+
+func TestMysqlFlavorDetectionRequiresApproval(t *testing.T) {
+	m, err := NewMysqlCommander([]string{"--no-defaults", "--flavor=auto", "-h", "db", "-e", "SELECT 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &Config{ProfileName: "production", Profile: Profile{RequireApproval: true, ApprovalNamespace: "approvals"}}
+	if err := m.Prepare(conf); err == nil || !strings.Contains(err.Error(), "--flavor") {
+		t.Errorf("Prepare() error = %v, want --flavor to be required", err)
+	}
+}
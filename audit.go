@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/exec"
+)
+
+// auditAnnotation is the annotation of the pod and the ConfigMap holding the audit record.
+const auditAnnotation = "podsql/audit"
+
+// auditAnnotationQueryLimit is the length of the query kept in the pod annotation. Kubernetes limits the annotations
+// of an object to 256 KiB in total, so longer queries are truncated and identified by their SHA-256 instead.
+const auditAnnotationQueryLimit = 4096
+
+// AuditConfig selects where the audit records of the sessions are written. Any number of them can be enabled.
+type AuditConfig struct {
+	// File is a local JSONL file the records are appended to
+	File string `yaml:"file"`
+	// Pod records the session in an annotation of the pod, so that it appears in the audit log of the cluster
+	Pod bool `yaml:"pod"`
+	// ConfigMap keeps the record in a ConfigMap named <pod>-audit, which is not deleted with the pod
+	ConfigMap bool                `yaml:"configMap"`
+	Webhook   *AuditWebhookConfig `yaml:"webhook"`
+	// RedactLiterals replaces the string and number literals of the queries with ?
+	RedactLiterals bool `yaml:"redactLiterals"`
+}
+
+// AuditWebhookConfig posts the records as JSON to the URL.
+type AuditWebhookConfig struct {
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+func (c AuditConfig) enabled() bool {
+	return c.File != "" || c.Pod || c.ConfigMap || c.Webhook != nil
+}
+
+// AuditRecord is a query or an interactive session run through podsql.
+type AuditRecord struct {
+	User        string    `json:"user"`
+	KubeContext string    `json:"kubeContext"`
+	Namespace   string    `json:"namespace"`
+	Pod         string    `json:"pod"`
+	Profile     string    `json:"profile,omitempty"`
+	Engine      string    `json:"engine"`
+	Host        string    `json:"host"`
+	Port        string    `json:"port,omitempty"`
+	Database    string    `json:"database,omitempty"`
+	DbUser      string    `json:"dbUser,omitempty"`
+	Interactive bool      `json:"interactive"`
+	Query       string    `json:"query,omitempty"`
+	QuerySHA256 string    `json:"querySha256,omitempty"`
+	StartTime   time.Time `json:"startTime"`
+	EndTime     time.Time `json:"endTime"`
+	ExitCode    int       `json:"exitCode"`
+	RowCount    *int      `json:"rowCount,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	// Recording is the local file of the recording of an interactive session
+	Recording string `json:"recording,omitempty"`
+
+	rowCounter RowCounter
+}
+
+// RowCounter is implemented by DBCommanders whose output reports the number of rows in a format that
+// cannot be confused with the result data. The other clients leave the row count out of the record.
+type RowCounter interface {
+	// CountRows returns the number of rows reported in the output, or nil if it does not report any
+	CountRows(output string) *int
+}
+
+// newAuditRecord starts the record of a session, or returns nil if auditing is disabled.
+func newAuditRecord(conf *Config, podName string, dbCommander DBCommander) *AuditRecord {
+	if !conf.Audit.enabled() {
+		return nil
+	}
+	connectInfo := dbCommander.ConnectInfo()
+	record := &AuditRecord{
+		KubeContext: currentKubeContext(),
+		Namespace:   conf.Namespace,
+		Pod:         podName,
+		Profile:     conf.ProfileName,
+		Engine:      dbCommander.CommandType().CommandName(),
+		Host:        connectInfo.Server,
+		Port:        connectInfo.Port,
+		Database:    connectInfo.DbName,
+		DbUser:      connectInfo.User,
+		Interactive: dbCommander.IsInteractive(),
+		StartTime:   time.Now(),
+	}
+	if c, ok := dbCommander.(RowCounter); ok {
+		record.rowCounter = c
+	}
+	if u, err := user.Current(); err == nil {
+		record.User = u.Username
+	}
+	if !record.Interactive {
+		record.Query = dbCommander.Query()
+		if conf.Audit.RedactLiterals {
+			record.Query = redactLiterals(record.Query, dbCommander.CommandType())
+		}
+	}
+	return record
+}
+
+// annotate adds the record to the pod when the pod sink is enabled. The end of the session is not known yet.
+func (r *AuditRecord) annotate(conf *Config, pod *corev1.Pod) {
+	if r == nil || !conf.Audit.Pod {
+		return
+	}
+	annotated := *r
+	if len(annotated.Query) > auditAnnotationQueryLimit {
+		sum := sha256.Sum256([]byte(r.Query))
+		annotated.Query = strings.ToValidUTF8(r.Query[:auditAnnotationQueryLimit], "") + "..."
+		annotated.QuerySHA256 = hex.EncodeToString(sum[:])
+	}
+	data, err := json.Marshal(annotated)
+	if err != nil {
+		return
+	}
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[auditAnnotation] = string(data)
+}
+
+// finish completes the record with the result of the session and writes it to the sinks.
+// Failures are reported as warnings so that they do not hide the result of the query.
+func (r *AuditRecord) finish(conf *Config, output string, exitCode int, err error) {
+	if r == nil {
+		return
+	}
+	r.EndTime = time.Now()
+	r.ExitCode = exitCode
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if !r.Interactive && r.rowCounter != nil {
+		r.RowCount = r.rowCounter.CountRows(output)
+	}
+	if werr := writeAuditRecord(conf, r); werr != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: failed to write audit record: %v\n", werr)
+	}
+}
+
+func writeAuditRecord(conf *Config, r *AuditRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	errs := []error{}
+	if conf.Audit.File != "" {
+		errs = append(errs, appendAuditFile(conf.Audit.File, data))
+	}
+	if conf.Audit.ConfigMap {
+		errs = append(errs, createAuditConfigMap(conf.Namespace, r, data))
+	}
+	if conf.Audit.Webhook != nil {
+		errs = append(errs, postAuditWebhook(*conf.Audit.Webhook, data))
+	}
+	return errors.Join(errs...)
+}
+
+func appendAuditFile(p string, data []byte) error {
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", p, err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write %s: %w", p, err)
+	}
+	return nil
+}
+
+func createAuditConfigMap(namespace string, r *AuditRecord, data []byte) error {
+	clientset, _, err := newClientset()
+	if err != nil {
+		return fmt.Errorf("failed to create clientset: %w", err)
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%s-audit", r.Pod),
+			Namespace:   namespace,
+			Annotations: map[string]string{auditAnnotation: "true"},
+		},
+		Data: map[string]string{"record.json": string(data)},
+	}
+	if _, err := clientset.CoreV1().ConfigMaps(namespace).Create(context.Background(), configMap, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create audit configmap: %w", err)
+	}
+	return nil
+}
+
+func postAuditWebhook(c AuditWebhookConfig, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.Headers {
+		req.Header.Set(k, os.ExpandEnv(v))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post audit record: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to post audit record: %s", resp.Status)
+	}
+	return nil
+}
+
+// currentKubeContext returns the current context of the kubeconfig that newClientset uses.
+func currentKubeContext() string {
+	config, err := clientcmd.LoadFromFile(kubeconfigPath())
+	if err != nil {
+		return ""
+	}
+	return config.CurrentContext
+}
+
+// exitCodeOf returns the exit code of the command from the error of the exec, or -1 if it did not exit.
+func exitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus()
+	}
+	return -1
+}
+
+// containerExitCode returns the exit code of the terminated container, or -1 if it has not terminated.
+func containerExitCode(pod *corev1.Pod, container string) int {
+	if pod == nil {
+		return -1
+	}
+	for _, st := range pod.Status.ContainerStatuses {
+		if st.Name == container && st.State.Terminated != nil {
+			return int(st.State.Terminated.ExitCode)
+		}
+	}
+	return -1
+}
+
+// redactLiterals replaces the string and number literals of the query with ?.
+// The queries of MongoDB and Redis are not SQL, so they are redacted entirely.
+func redactLiterals(query string, dialect CommandType) string {
+	if !isSQLDialect(dialect) {
+		return "<redacted>"
+	}
+	var b strings.Builder
+	last := 0
+	tokens := tokenizeSQL(query, dialect)
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.kind != sqlString && !isNumber(t) {
+			continue
+		}
+		end := t.end
+		// 1.5 is tokenized as 1 . 5
+		if isNumber(t) && i+2 < len(tokens) && tokens[i+1].text == "." && tokens[i+1].pos == end &&
+			isNumber(tokens[i+2]) && tokens[i+2].pos == tokens[i+1].end {
+			end = tokens[i+2].end
+			i += 2
+		}
+		b.WriteString(query[last:t.pos])
+		b.WriteString("?")
+		last = end
+	}
+	b.WriteString(query[last:])
+	return b.String()
+}
+
+func isNumber(t sqlToken) bool {
+	return t.kind == sqlWord && t.text[0] >= '0' && t.text[0] <= '9'
+}
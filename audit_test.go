@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestAuditRecordAnnotate(t *testing.T) {
+	conf := &Config{Audit: AuditConfig{Pod: true}}
+	long := "INSERT INTO t VALUES ('" + strings.Repeat("é", 300*1024) + "')"
+	tests := []struct {
+		name      string
+		query     string
+		wantQuery string
+		wantHash  string
+	}{
+		{name: "short query", query: "SELECT 1", wantQuery: "SELECT 1"},
+		{name: "long query", query: long, wantHash: func() string { sum := sha256.Sum256([]byte(long)); return hex.EncodeToString(sum[:]) }()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &AuditRecord{Pod: "podsql-1", Query: tt.query}
+			pod := &corev1.Pod{}
+			r.annotate(conf, pod)
+
+			annotation := pod.Annotations[auditAnnotation]
+			var got AuditRecord
+			if err := json.Unmarshal([]byte(annotation), &got); err != nil {
+				t.Fatalf("annotation %q is not a record: %v", annotation, err)
+			}
+			if r.Query != tt.query || r.QuerySHA256 != "" {
+				t.Errorf("annotate() changed the record")
+			}
+			if tt.wantHash == "" {
+				if got.Query != tt.wantQuery || got.QuerySHA256 != "" {
+					t.Errorf("annotated query = %q, %q, want %q", got.Query, got.QuerySHA256, tt.wantQuery)
+				}
+				return
+			}
+			if len(annotation) > 2*auditAnnotationQueryLimit {
+				t.Errorf("annotation is %d bytes", len(annotation))
+			}
+			if !utf8.ValidString(got.Query) || !strings.HasPrefix(tt.query, strings.TrimSuffix(got.Query, "...")) {
+				t.Errorf("annotated query %q is not a prefix of the query", got.Query)
+			}
+			if got.QuerySHA256 != tt.wantHash {
+				t.Errorf("querySha256 = %s, want %s", got.QuerySHA256, tt.wantHash)
+			}
+		})
+	}
+}
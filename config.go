@@ -1,17 +1,57 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"os/user"
+	"path"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/go-yaml/yaml"
 	"github.com/urfave/cli/v2"
 )
 
 type Config struct {
-	Timezone  string `yaml:"timezone"`
-	Namespace string `yaml:"namespace"`
+	Timezone       string `yaml:"timezone"`
+	Namespace      string `yaml:"namespace"`
+	ServiceAccount string `yaml:"serviceAccount"`
+
+	Profiles  map[string]Profile `yaml:"profiles"`
+	Audit     AuditConfig        `yaml:"audit"`
+	Recording RecordingConfig    `yaml:"recording"`
+	History   HistoryConfig      `yaml:"history"`
+
+	// ProfileName and Profile are the profile selected with --profile
+	ProfileName string        `yaml:"-"`
+	Profile     Profile       `yaml:"-"`
+	TLS         TLSConfig     `yaml:"-"`
+	ReadOnly    bool          `yaml:"-"`
+	AssumeYes   bool          `yaml:"-"`
+	RecordPath  string        `yaml:"-"`
+	Timeout     time.Duration `yaml:"-"`
+	CopyIn      []FileMapping `yaml:"-"`
+	CopyOut     []FileMapping `yaml:"-"`
+}
+
+// Profile is a named set of settings in the config file, selected with --profile.
+type Profile struct {
+	TLSConfig   `yaml:",inline"`
+	Credentials CredentialsConfig `yaml:"credentials"`
+	ReadOnly    bool              `yaml:"readOnly"`
+	Protected   bool              `yaml:"protected"`
+	Recording   *RecordingConfig  `yaml:"recording"`
+	// RequireApproval refuses to run queries and sessions without an access request approved by another user
+	RequireApproval bool `yaml:"requireApproval"`
+	// ApprovalNamespace holds the approvals, which the approvers create and the requesters can only read and delete
+	ApprovalNamespace string `yaml:"approvalNamespace"`
+}
+
+// FileMapping is a pair of a path in the bastion pod and a path on the local machine.
+type FileMapping struct {
+	Remote string
+	Local  string
 }
 
 func DefaultConfigPath() (string, error) {
@@ -23,6 +63,12 @@ func DefaultConfigPath() (string, error) {
 }
 
 func NewConfig(c *cli.Context) (*Config, error) {
+	return newConfig(c, c.String("profile"))
+}
+
+// newConfig reads the config with the profile of the name, or without a profile if the name is empty,
+// and applies the global flags to it.
+func newConfig(c *cli.Context, profileName string) (*Config, error) {
 	confPath, err := DefaultConfigPath()
 	if err != nil {
 		return nil, err
@@ -31,28 +77,96 @@ func NewConfig(c *cli.Context) (*Config, error) {
 		confPath = c.String("config")
 	}
 
-	if _, err := os.Stat(confPath); os.IsNotExist(err) {
-		return &Config{
-			Timezone:  c.String("timezone"),
-			Namespace: c.String("namespace"),
-		}, nil
+	conf := &Config{
+		Timezone:       c.String("timezone"),
+		Namespace:      c.String("namespace"),
+		ServiceAccount: c.String("service-account"),
+	}
+	if _, err := os.Stat(confPath); !os.IsNotExist(err) {
+		conf, err = readConfig(confPath)
+		if err != nil {
+			return nil, err
+		}
+		if c.IsSet("timezone") {
+			conf.Timezone = c.String("timezone")
+		}
+		if c.IsSet("namespace") {
+			conf.Namespace = c.String("namespace")
+		}
+		if c.IsSet("service-account") {
+			conf.ServiceAccount = c.String("service-account")
+		}
 	}
 
-	conf, err := readConfig(confPath)
-	if err != nil {
+	if profileName != "" {
+		profile, ok := conf.Profiles[profileName]
+		if !ok {
+			return nil, fmt.Errorf("profile %s is not found in %s", profileName, confPath)
+		}
+		conf.ProfileName = profileName
+		conf.Profile = profile
+		conf.TLS = profile.TLSConfig
+		conf.ReadOnly = profile.ReadOnly
+		if profile.Recording != nil {
+			conf.Recording = *profile.Recording
+		}
+	}
+	if c.IsSet("read-only") {
+		conf.ReadOnly = c.Bool("read-only")
+	}
+	conf.AssumeYes = c.Bool("yes")
+	conf.RecordPath = c.String("record")
+	conf.Timeout = c.Duration("timeout")
+	if c.IsSet("ssl-mode") {
+		conf.TLS.SSLMode = c.String("ssl-mode")
+	}
+	if c.IsSet("ssl-ca") {
+		conf.TLS.SSLCA = c.String("ssl-ca")
+	}
+	if c.IsSet("ssl-cert") {
+		conf.TLS.SSLCert = c.String("ssl-cert")
+	}
+	if c.IsSet("ssl-key") {
+		conf.TLS.SSLKey = c.String("ssl-key")
+	}
+	if err := conf.TLS.validate(); err != nil {
 		return nil, err
 	}
 
-	if c.IsSet("timezone") {
-		conf.Timezone = c.String("timezone")
+	for _, v := range c.StringSlice("copy-in") {
+		local, remote, err := splitFileMapping(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --copy-in: %w", err)
+		}
+		if fi, err := os.Stat(local); err != nil {
+			return nil, fmt.Errorf("invalid --copy-in: %w", err)
+		} else if !fi.Mode().IsRegular() {
+			return nil, fmt.Errorf("invalid --copy-in: %s is not a regular file", local)
+		}
+		if !path.IsAbs(remote) {
+			remote = path.Join(copyInDir, remote)
+		}
+		conf.CopyIn = append(conf.CopyIn, FileMapping{Remote: remote, Local: local})
 	}
-	if c.IsSet("namespace") {
-		conf.Namespace = c.String("namespace")
+	for _, v := range c.StringSlice("copy-out") {
+		remote, local, err := splitFileMapping(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --copy-out: %w", err)
+		}
+		conf.CopyOut = append(conf.CopyOut, FileMapping{Remote: remote, Local: local})
 	}
 
 	return conf, nil
 }
 
+func splitFileMapping(v string) (string, string, error) {
+	src, dst, found := strings.Cut(v, ":")
+	if !found || src == "" || dst == "" {
+		return "", "", fmt.Errorf("%q must be in the form <src>:<dst>", v)
+	}
+	return src, dst, nil
+}
+
 func readConfig(p string) (*Config, error) {
 	data, err := os.ReadFile(p)
 	if err != nil {
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+func ConnectCommands() *cli.Command {
+	return &cli.Command{
+		Name:            "connect",
+		Usage:           "connect to the database of a connection url (mysql://, postgresql://, postgres://, sqlserver://, mongodb://, redis://, clickhouse://)",
+		ArgsUsage:       "<url> [client options]",
+		SkipFlagParsing: true,
+		Action:          executeConnectAction,
+	}
+}
+
+func executeConnectAction(c *cli.Context) error {
+	config, err := NewConfig(c)
+	if err != nil {
+		return err
+	}
+	podName, err := CreatePodName("podsql")
+	if err != nil {
+		return err
+	}
+	args := c.Args().Slice()
+	if len(args) == 0 {
+		return fmt.Errorf("connection url is required")
+	}
+	commandType := DetermineDatabaseTypeFronConnectionString(args[0])
+	if commandType == Unknown {
+		return fmt.Errorf("unsupported connection url: %s", args[0])
+	}
+	dbCommander, err := NewDBCommander(commandType.CommandName(), append([]string{"--url", args[0]}, args[1:]...))
+	if err != nil {
+		return err
+	}
+	return runDBCommander(config, podName, dbCommander)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const credentialResolveTimeout = 30 * time.Second
+
+// CredentialsConfig configures where the credentials of a profile are taken from.
+// The providers are consulted in the order of passwordCommand, vault and awsSecretsManager,
+// and only fill in the fields that are not given on the command line.
+type CredentialsConfig struct {
+	PasswordCommand   string                   `yaml:"passwordCommand"`
+	Vault             *VaultConfig             `yaml:"vault"`
+	AWSSecretsManager *AWSSecretsManagerConfig `yaml:"awsSecretsManager"`
+}
+
+// CredentialResolver looks up the credentials of the connection in an external secret store.
+// The returned ConnectInfo may also contain the host, port and database name when the store has them.
+type CredentialResolver interface {
+	Resolve(ctx context.Context) (ConnectInfo, error)
+}
+
+func newCredentialResolvers(c CredentialsConfig) []CredentialResolver {
+	resolvers := []CredentialResolver{}
+	if c.PasswordCommand != "" {
+		resolvers = append(resolvers, &commandCredentialResolver{command: c.PasswordCommand})
+	}
+	if c.Vault != nil {
+		resolvers = append(resolvers, newVaultCredentialResolver(*c.Vault))
+	}
+	if c.AWSSecretsManager != nil {
+		resolvers = append(resolvers, newAWSSecretsManagerCredentialResolver(*c.AWSSecretsManager))
+	}
+	return resolvers
+}
+
+// ConnectDefaulter is implemented by DBCommanders that fill in the defaults of the client, such as the port,
+// for the parameters not given. It returns the parameters that were defaulted, which the credential providers may replace.
+type ConnectDefaulter interface {
+	DefaultConnectInfo() ConnectInfo
+}
+
+// resolveCredentials fills in the connection of the DBCommander with the credential providers of the profile.
+func resolveCredentials(conf *Config, dbCommander DBCommander) error {
+	resolvers := newCredentialResolvers(conf.Profile.Credentials)
+	if len(resolvers) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), credentialResolveTimeout)
+	defer cancel()
+	connectInfo := dbCommander.ConnectInfo()
+	var defaults ConnectInfo
+	if d, ok := dbCommander.(ConnectDefaulter); ok {
+		defaults = d.DefaultConnectInfo()
+		connectInfo = withoutDefaults(connectInfo, defaults)
+	}
+	for _, r := range resolvers {
+		if connectInfo.Password != "" {
+			break
+		}
+		resolved, err := r.Resolve(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to resolve credentials of profile %s: %w", conf.ProfileName, err)
+		}
+		connectInfo = resolved.merge(connectInfo)
+	}
+	dbCommander.SetConnectInfo(defaults.merge(connectInfo))
+	return nil
+}
+
+// withoutDefaults clears the parameters that still have the default values, so that the stored ones take precedence.
+func withoutDefaults(connectInfo, defaults ConnectInfo) ConnectInfo {
+	if defaults.Server != "" && connectInfo.Server == defaults.Server {
+		connectInfo.Server = ""
+	}
+	if defaults.Port != "" && connectInfo.Port == defaults.Port {
+		connectInfo.Port = ""
+	}
+	if defaults.User != "" && connectInfo.User == defaults.User {
+		connectInfo.User = ""
+	}
+	if defaults.DbName != "" && connectInfo.DbName == defaults.DbName {
+		connectInfo.DbName = ""
+	}
+	return connectInfo
+}
+
+// commandCredentialResolver runs a local command such as `op read op://vault/db/password` and takes its output as the password.
+type commandCredentialResolver struct {
+	command string
+}
+
+func (r *commandCredentialResolver) Resolve(ctx context.Context) (ConnectInfo, error) {
+	shell, flag := "/bin/sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	cmd := exec.CommandContext(ctx, shell, flag, r.command)
+	// The command may ask the user to sign in
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return ConnectInfo{}, fmt.Errorf("failed to run passwordCommand: %w", err)
+	}
+	password := strings.TrimRight(string(out), "\r\n")
+	if password == "" {
+		return ConnectInfo{}, fmt.Errorf("passwordCommand returned an empty password")
+	}
+	return ConnectInfo{Password: password}, nil
+}
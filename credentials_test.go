@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVaultCredentialResolver(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		response string
+		want     ConnectInfo
+	}{
+		{
+			name:     "kv v1",
+			path:     "kv/db/prod",
+			response: `{"data": {"username": "app", "password": "s3cret"}}`,
+			want:     ConnectInfo{User: "app", Password: "s3cret"},
+		},
+		{
+			name:     "kv v2",
+			path:     "secret/data/db/prod",
+			response: `{"data": {"data": {"username": "app", "password": "s3cret"}, "metadata": {"version": 3}}}`,
+			want:     ConnectInfo{User: "app", Password: "s3cret"},
+		},
+		{
+			name:     "kv v1 with a data key",
+			path:     "kv/db/prod",
+			response: `{"data": {"data": {"x": "y"}, "username": "app", "password": "s3cret"}}`,
+			want:     ConnectInfo{User: "app", Password: "s3cret"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/"+tt.path {
+					t.Errorf("path = %s, want /v1/%s", r.URL.Path, tt.path)
+				}
+				if r.Header.Get("X-Vault-Token") != "token" || r.Header.Get("X-Vault-Namespace") != "team" {
+					t.Errorf("headers = %v", r.Header)
+				}
+				io.WriteString(w, tt.response)
+			}))
+			defer server.Close()
+
+			r := newVaultCredentialResolver(VaultConfig{Address: server.URL, Namespace: "team", Path: tt.path})
+			r.token = func() (string, error) { return "token", nil }
+			got, err := r.Resolve(context.Background())
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVaultCredentialResolverErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{name: "forbidden", status: http.StatusForbidden, body: `{"errors": ["permission denied"]}`, wantErr: "permission denied"},
+		{name: "no password", status: http.StatusOK, body: `{"data": {"username": "app"}}`, wantErr: "has no password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+
+			r := newVaultCredentialResolver(VaultConfig{Address: server.URL, Path: "kv/db"})
+			r.token = func() (string, error) { return "token", nil }
+			if _, err := r.Resolve(context.Background()); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Resolve() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAWSSecretsManagerCredentialResolver(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" {
+			t.Errorf("request = %s %s", r.Method, r.Header.Get("X-Amz-Target"))
+		}
+		if got := r.Header.Get("X-Amz-Date"); got != "20240501T120000Z" {
+			t.Errorf("X-Amz-Date = %s", got)
+		}
+		if got := r.Header.Get("X-Amz-Security-Token"); got != "session" {
+			t.Errorf("X-Amz-Security-Token = %s", got)
+		}
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/20240501/ap-northeast-1/secretsmanager/aws4_request, SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target, Signature=") {
+			t.Errorf("Authorization = %s", auth)
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["SecretId"] != "rds/prod" {
+			t.Errorf("body = %v, %v", body, err)
+		}
+		secret := `{"engine": "postgres", "host": "db.example.com", "port": 5433, "username": "app", "password": "s3cret", "dbname": "app"}`
+		json.NewEncoder(w).Encode(map[string]string{"SecretString": secret})
+	}))
+	defer server.Close()
+
+	r := newAWSSecretsManagerCredentialResolver(AWSSecretsManagerConfig{SecretID: "rds/prod", Region: "ap-northeast-1", Endpoint: server.URL})
+	r.credentials = func() (AWSCredentials, error) {
+		return AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "session"}, nil
+	}
+	r.now = func() time.Time { return now }
+	got, err := r.Resolve(context.Background())
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	want := ConnectInfo{Server: "db.example.com", Port: "5433", User: "app", Password: "s3cret", DbName: "app"}
+	if got != want {
+		t.Errorf("Resolve() = %+v, want %+v", got, want)
+	}
+}
+
+// TestSignSigV4 checks the signature with the example of the AWS documentation.
+func TestSignSigV4(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	creds := AWSCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signSigV4(req, nil, creds, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %s, want %s", got, want)
+	}
+}
+
+func TestParseSecretString(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want ConnectInfo
+	}{
+		{name: "rds", s: `{"username": "app", "password": "p", "host": "h", "port": 3306, "dbname": "d"}`, want: ConnectInfo{Server: "h", Port: "3306", User: "app", Password: "p", DbName: "d"}},
+		{name: "port as string", s: `{"password": "p", "port": "5432"}`, want: ConnectInfo{Port: "5432", Password: "p"}},
+		{name: "plain password", s: `p{a"ss`, want: ConnectInfo{Password: `p{a"ss`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSecretString(tt.s); got != tt.want {
+				t.Errorf("parseSecretString() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+type fakeCredentialsCommander struct {
+	DBCommander
+	connectInfo ConnectInfo
+	defaults    ConnectInfo
+}
+
+func (f *fakeCredentialsCommander) ConnectInfo() ConnectInfo        { return f.connectInfo }
+func (f *fakeCredentialsCommander) SetConnectInfo(c ConnectInfo)    { f.connectInfo = c }
+func (f *fakeCredentialsCommander) DefaultConnectInfo() ConnectInfo { return f.defaults }
+
+func TestResolveCredentialsPrecedence(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := `{"host": "stored.example.com", "port": 3307, "username": "stored", "password": "s3cret", "dbname": "stored"}`
+		json.NewEncoder(w).Encode(map[string]string{"SecretString": secret})
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	conf := &Config{Profile: Profile{Credentials: CredentialsConfig{
+		AWSSecretsManager: &AWSSecretsManagerConfig{SecretID: "rds/prod", Region: "ap-northeast-1", Endpoint: server.URL},
+	}}}
+
+	tests := []struct {
+		name     string
+		given    ConnectInfo
+		defaults ConnectInfo
+		want     ConnectInfo
+	}{
+		{
+			name:     "defaults are replaced",
+			given:    ConnectInfo{Server: "given.example.com", Port: "3306", User: "root"},
+			defaults: ConnectInfo{Port: "3306", User: "root"},
+			want:     ConnectInfo{Server: "given.example.com", Port: "3307", User: "stored", Password: "s3cret", DbName: "stored"},
+		},
+		{
+			name:  "given values win",
+			given: ConnectInfo{Server: "given.example.com", Port: "3306", User: "admin", DbName: "app"},
+			want:  ConnectInfo{Server: "given.example.com", Port: "3306", User: "admin", Password: "s3cret", DbName: "app"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &fakeCredentialsCommander{connectInfo: tt.given, defaults: tt.defaults}
+			if err := resolveCredentials(conf, c); err != nil {
+				t.Fatalf("resolveCredentials() error = %v", err)
+			}
+			if c.connectInfo != tt.want {
+				t.Errorf("connectInfo = %+v, want %+v", c.connectInfo, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/urfave/cli/v2"
+)
+
+type DumpOptions struct {
+	Tables        []string
+	ExcludeTables []string
+}
+
+// Dumper is implemented by DBCommanders that can take and restore logical backups.
+// The commands read the archive from stdin and write it to stdout.
+type Dumper interface {
+	DumpCommand(opts DumpOptions) (string, error)
+	RestoreCommand(opts DumpOptions) (string, error)
+}
+
+var dumpClients = []string{"mysql", "psql", "sqlcmd"}
+
+func DumpCommands() *cli.Command {
+	subcommands := []*cli.Command{}
+	for _, name := range dumpClients {
+		subcommands = append(subcommands, &cli.Command{
+			Name:      name,
+			Usage:     fmt.Sprintf("dump a database with the %s connection options", name),
+			ArgsUsage: fmt.Sprintf("[options] -- <%s options>", name),
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Usage:   "output file path (default: stdout)",
+				},
+				&cli.StringSliceFlag{
+					Name:  "table",
+					Usage: "table to include in the dump",
+				},
+				&cli.StringSliceFlag{
+					Name:  "exclude-table",
+					Usage: "table to exclude from the dump",
+				},
+			},
+			Action: executeDumpAction,
+		})
+	}
+	return &cli.Command{
+		Name:        "dump",
+		Usage:       "dump a database to a local file",
+		Subcommands: subcommands,
+	}
+}
+
+func RestoreCommands() *cli.Command {
+	subcommands := []*cli.Command{}
+	for _, name := range dumpClients {
+		subcommands = append(subcommands, &cli.Command{
+			Name:      name,
+			Usage:     fmt.Sprintf("restore a database with the %s connection options", name),
+			ArgsUsage: fmt.Sprintf("[options] -- <%s options>", name),
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "input",
+					Aliases: []string{"i"},
+					Usage:   "input file path (default: stdin)",
+				},
+				&cli.StringSliceFlag{
+					Name:  "table",
+					Usage: "table to restore",
+				},
+				&cli.StringSliceFlag{
+					Name:  "exclude-table",
+					Usage: "table not to restore",
+				},
+			},
+			Action: executeRestoreAction,
+		})
+	}
+	return &cli.Command{
+		Name:        "restore",
+		Usage:       "restore a database from a local file",
+		Subcommands: subcommands,
+	}
+}
+
+func newDumper(c *cli.Context, conf *Config) (DBCommander, Dumper, error) {
+	dbCommander, err := NewDBCommander(c.Command.Name, c.Args().Slice())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := prepareDBCommander(conf, dbCommander); err != nil {
+		return nil, nil, err
+	}
+	dumper, ok := dbCommander.(Dumper)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s does not support dump and restore", c.Command.Name)
+	}
+	return dbCommander, dumper, nil
+}
+
+func executeDumpAction(c *cli.Context) error {
+	config, err := NewConfig(c)
+	if err != nil {
+		return err
+	}
+	podName, err := CreatePodName("podsql")
+	if err != nil {
+		return err
+	}
+	dbCommander, dumper, err := newDumper(c, config)
+	if err != nil {
+		return err
+	}
+	command, err := dumper.DumpCommand(DumpOptions{
+		Tables:        c.StringSlice("table"),
+		ExcludeTables: c.StringSlice("exclude-table"),
+	})
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if p := c.String("output"); p != "" && p != "-" {
+		f, err := os.Create(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	return StreamPod(config, podName, dbCommander, command, nil, out)
+}
+
+func executeRestoreAction(c *cli.Context) error {
+	config, err := NewConfig(c)
+	if err != nil {
+		return err
+	}
+	if config.ReadOnly {
+		return fmt.Errorf("restore is not allowed in read-only mode")
+	}
+	podName, err := CreatePodName("podsql")
+	if err != nil {
+		return err
+	}
+	dbCommander, dumper, err := newDumper(c, config)
+	if err != nil {
+		return err
+	}
+	command, err := dumper.RestoreCommand(DumpOptions{
+		Tables:        c.StringSlice("table"),
+		ExcludeTables: c.StringSlice("exclude-table"),
+	})
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if p := c.String("input"); p != "" && p != "-" {
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	return StreamPod(config, podName, dbCommander, command, in, os.Stdout)
+}
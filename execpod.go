@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -13,13 +14,24 @@ import (
 )
 
 func ExecPod(conf *Config, podName string, dbCommander DBCommander) error {
+	record := newAuditRecord(conf, podName, dbCommander)
+	err := execPod(conf, podName, dbCommander, record)
+	record.finish(conf, "", exitCodeOf(err), err)
+	return err
+}
+
+func execPod(conf *Config, podName string, dbCommander DBCommander, record *AuditRecord) error {
 	clientset, config, err := newClientset()
 	if err != nil {
 		return fmt.Errorf("failed to create clientset: %w", err)
 	}
+	if err := checkApproval(context.Background(), clientset, conf, podName, dbCommander, time.Now()); err != nil {
+		return err
+	}
 
 	// Define specifications to create pods
-	podSpec := createExecPodSpec(podName, conf.Namespace, conf.Timezone, dbCommander)
+	podSpec := createExecPodSpec(podName, conf, dbCommander)
+	record.annotate(conf, podSpec)
 
 	// create pods
 	podsClient := clientset.CoreV1().Pods(conf.Namespace)
@@ -28,17 +40,45 @@ func ExecPod(conf *Config, podName string, dbCommander DBCommander) error {
 		return fmt.Errorf("failed to create pod: %w", err)
 	}
 
+	// Create ConfigMap to hold the files the client needs
+	if files := shippedFiles(dbCommander); len(files) > 0 {
+		configMap := createConfigMapSpec(fmt.Sprintf("%s-cm", podName), conf.Namespace, pod, files)
+		_, err = clientset.CoreV1().ConfigMaps(conf.Namespace).Create(context.Background(), configMap, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to create configmap: %w", err)
+		}
+	}
+
 	// Create Secret for DB_USER and DB_PASSWORD with argument values
 	secret := createBasicAuthSecretSpec(fmt.Sprintf("%s-secret", podName), conf.Namespace, dbCommander.ConnectInfo(), pod)
 	_, err = clientset.CoreV1().Secrets(conf.Namespace).Create(context.Background(), secret, metav1.CreateOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to create secret: %w", err)
 	}
+	if err := createTLSSecret(clientset, conf, pod); err != nil {
+		return err
+	}
 
 	if err = waitForPodRunning(context.Background(), podsClient, podName); err != nil {
 		return err
 	}
 
+	history, err := newClientHistory(conf, dbCommander)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: %v\n", err)
+	}
+
+	// Transfer the files before the client starts
+	container := dbCommander.CommandType().String()
+	if files := append(copyInFiles(conf.CopyIn), history.podFiles()...); len(files) > 0 {
+		if err := copyFilesToPod(context.Background(), clientset, config, conf.Namespace, podName, container, files); err != nil {
+			if delerr := deletePod(podsClient, podName); delerr != nil {
+				return fmt.Errorf("%w, failed to delete pod: %w", err, delerr)
+			}
+			return err
+		}
+	}
+
 	req := clientset.CoreV1().RESTClient().
 		Post().
 		Resource("pods").
@@ -46,8 +86,8 @@ func ExecPod(conf *Config, podName string, dbCommander DBCommander) error {
 		Namespace(conf.Namespace).
 		SubResource("exec").
 		VersionedParams(&corev1.PodExecOptions{
-			Container: dbCommander.CommandType().String(),
-			Command:   []string{"/bin/sh", "-c", dbCommander.InteractiveCommand()},
+			Container: container,
+			Command:   []string{"/bin/sh", "-c", history.wrapCommand(dbCommander.InteractiveCommand())},
 			Stdin:     true,
 			Stdout:    true,
 			Stderr:    false,
@@ -65,15 +105,33 @@ func ExecPod(conf *Config, podName string, dbCommander DBCommander) error {
 		Raw: true,
 	}
 
+	recorder, err := startRecording(conf, podName, tty.GetSize())
+	if err != nil {
+		if delerr := deletePod(podsClient, podName); delerr != nil {
+			return fmt.Errorf("%w, failed to delete pod: %w", err, delerr)
+		}
+		return err
+	}
+	if recorder != nil && record != nil {
+		record.Recording = recorder.path
+	}
+
 	err = tty.Safe(func() error {
 		return exec.StreamWithContext(context.Background(), remotecommand.StreamOptions{
-			Stdin:             tty.In,
-			Stdout:            tty.Out,
+			Stdin:             recorder.Input(tty.In),
+			Stdout:            recorder.Output(tty.Out),
 			Stderr:            nil,
 			Tty:               true,
-			TerminalSizeQueue: tty.MonitorSize(tty.GetSize()),
+			TerminalSizeQueue: recorder.SizeQueue(tty.MonitorSize(tty.GetSize())),
 		})
 	})
+	if recerr := recorder.Close(); recerr != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: %v\n", recerr)
+	}
+	// Save the history before the pod is deleted
+	if histerr := history.save(context.Background(), clientset, config, conf.Namespace, podName, container); histerr != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: failed to save history: %v\n", histerr)
+	}
 	if err != nil {
 		if delerr := deletePod(podsClient, podName); delerr != nil {
 			return fmt.Errorf("failed to execute command: %w, failed to delete pod: %w", err, delerr)
@@ -88,22 +146,43 @@ func ExecPod(conf *Config, podName string, dbCommander DBCommander) error {
 	return nil
 }
 
-func createExecPodSpec(podName, namespace, timezone string, dbCommander DBCommander) *corev1.Pod {
-	return &corev1.Pod{
+func createExecPodSpec(podName string, conf *Config, dbCommander DBCommander) *corev1.Pod {
+	volumes := []corev1.Volume{}
+	volumeMounts := []corev1.VolumeMount{}
+	if len(shippedFiles(dbCommander)) > 0 {
+		volumes = append(volumes, corev1.Volume{
+			Name: "query-volume",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: fmt.Sprintf("%s-cm", podName),
+					},
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{Name: "query-volume", MountPath: "/sql"})
+	}
+
+	pod := customizePod(&corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      podName,
-			Namespace: namespace,
+			Namespace: conf.Namespace,
 		},
 		Spec: corev1.PodSpec{
+			ServiceAccountName: conf.ServiceAccount,
+			Volumes:            volumes,
 			Containers: []corev1.Container{
 				{
-					Name:    dbCommander.CommandType().String(),
-					Image:   dbCommander.ContainerImage(),
-					Env:     append(generateSecretEnvVars(podName, dbCommander), corev1.EnvVar{Name: "TZ", Value: timezone}),
-					Command: []string{"/bin/sh", "-c", "tail -f /dev/null"},
+					Name:         dbCommander.CommandType().String(),
+					Image:        dbCommander.ContainerImage(),
+					VolumeMounts: volumeMounts,
+					Env:          append(generateSecretEnvVars(podName, dbCommander), corev1.EnvVar{Name: "TZ", Value: conf.Timezone}),
+					Command:      []string{"/bin/sh", "-c", "tail -f /dev/null"},
 				},
 			},
 			RestartPolicy: corev1.RestartPolicyNever,
 		},
-	}
+	}, dbCommander)
+	mountTLSSecret(pod, conf.TLS)
+	return pod
 }
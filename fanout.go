@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+)
+
+// TabularOutputer is implemented by DBCommanders whose client can print the results as CSV or TSV with a header line,
+// which fanout merges into one table. It returns the field separator of the output.
+type TabularOutputer interface {
+	ConfigureTabularOutput() rune
+}
+
+func FanoutCommands() *cli.Command {
+	return &cli.Command{
+		Name:      "fanout",
+		Usage:     "run the same query against many databases in parallel and merge the results with a source column",
+		ArgsUsage: "[options] -- <client> <client options>",
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:  "profiles",
+				Usage: "profiles in the config file to run the query with, comma-separated or repeated",
+			},
+			&cli.StringFlag{
+				Name:  "hosts-file",
+				Usage: "file of the hosts to run the query on, one host[:port][/database] per line. {01..16} and {a,b} are expanded",
+			},
+			&cli.IntFlag{
+				Name:  "concurrency",
+				Usage: "number of pods to run at the same time",
+				Value: 4,
+			},
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "output format: table, csv or json",
+				Value: "table",
+			},
+		},
+		Action: executeFanoutAction,
+	}
+}
+
+// fanoutTarget is a database of a fanout: a profile, or a host of the hosts file, which uses the --profile.
+type fanoutTarget struct {
+	source  string
+	profile string
+	host    string
+	port    string
+	dbName  string
+}
+
+type fanoutResult struct {
+	header []string
+	rows   [][]string
+	err    error
+}
+
+func executeFanoutAction(c *cli.Context) error {
+	args := c.Args().Slice()
+	if len(args) == 0 {
+		return fmt.Errorf("client is required, e.g. podsql fanout --profiles a,b -- mysql -e 'SELECT 1'")
+	}
+	format := c.String("format")
+	if !slices.Contains([]string{"table", "csv", "json"}, format) {
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+	if c.Int("concurrency") < 1 {
+		return fmt.Errorf("--concurrency must be positive")
+	}
+	client, clientArgs := args[0], args[1:]
+	dbCommander, err := NewDBCommander(client, clientArgs)
+	if err != nil {
+		return err
+	}
+	if dbCommander.IsInteractive() {
+		return fmt.Errorf("fanout requires a query")
+	}
+
+	targets := []fanoutTarget{}
+	for _, profile := range c.StringSlice("profiles") {
+		targets = append(targets, fanoutTarget{source: profile, profile: profile})
+	}
+	if c.IsSet("hosts-file") {
+		hostTargets, err := readHostsFile(c.String("hosts-file"), c.String("profile"))
+		if err != nil {
+			return err
+		}
+		targets = append(targets, hostTargets...)
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("--profiles or --hosts-file is required")
+	}
+
+	results := runFanout(c, targets, client, clientArgs)
+
+	failed := 0
+	for i, r := range results {
+		if r.err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", targets[i].source, r.err)
+		}
+	}
+	if err := writeFanoutResults(os.Stdout, format, targets, results); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d targets failed", failed, len(targets))
+	}
+	return nil
+}
+
+// runFanout prepares the targets one by one, because resolving the credentials and the confirmation of protected
+// profiles may ask the user, then runs the pods with bounded concurrency. A failed target does not stop the others.
+func runFanout(c *cli.Context, targets []fanoutTarget, client string, clientArgs []string) []fanoutResult {
+	type job struct {
+		conf        *Config
+		podName     string
+		dbCommander DBCommander
+		separator   rune
+	}
+	results := make([]fanoutResult, len(targets))
+	jobs := make([]*job, len(targets))
+	for i, t := range targets {
+		conf, dbCommander, err := prepareFanoutTarget(c, t, client, clientArgs)
+		if err == nil {
+			err = confirmDestructiveQuery(conf, dbCommander)
+			// The query is confirmed, RunPod must not ask again in parallel
+			conf.AssumeYes = true
+		}
+		var podName string
+		if err == nil {
+			podName, err = CreatePodName(fmt.Sprintf("podsql-fanout-%d", i+1))
+		}
+		if err != nil {
+			results[i].err = err
+			continue
+		}
+		j := &job{conf: conf, podName: podName, dbCommander: dbCommander}
+		if o, ok := dbCommander.(TabularOutputer); ok {
+			j.separator = o.ConfigureTabularOutput()
+		}
+		jobs[i] = j
+	}
+
+	// Ctrl-C stops the targets that have not started yet, and the context aborts the ones whose pods are starting
+	// or running.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sem := make(chan struct{}, c.Int("concurrency"))
+	done := 0
+	report := func(i int) {
+		mu.Lock()
+		defer mu.Unlock()
+		done++
+		status := fmt.Sprintf("%d rows", len(results[i].rows))
+		if results[i].err != nil {
+			status = "failed"
+		}
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s: %s\n", done, len(jobs), targets[i].source, status)
+	}
+	for i, j := range jobs {
+		if j == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer report(i)
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				results[i].err = fmt.Errorf("interrupted before the query was run")
+				return
+			}
+
+			out, exitCode, err := runQuery(ctx, j.conf, j.podName, j.dbCommander)
+			if err == nil && exitCode != 0 {
+				err = fmt.Errorf("client exited with %d: %s", exitCode, lastLine(out))
+			}
+			if err == nil {
+				results[i].header, results[i].rows, err = parseFanoutOutput(out, j.separator)
+			}
+			results[i].err = err
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func prepareFanoutTarget(c *cli.Context, t fanoutTarget, client string, clientArgs []string) (*Config, DBCommander, error) {
+	conf, err := newConfig(c, t.profile)
+	if err != nil {
+		return nil, nil, err
+	}
+	dbCommander, err := NewDBCommander(client, clientArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if t.host != "" {
+		connectInfo := dbCommander.ConnectInfo()
+		connectInfo.Server = t.host
+		if t.port != "" {
+			connectInfo.Port = t.port
+		}
+		if t.dbName != "" {
+			connectInfo.DbName = t.dbName
+		}
+		dbCommander.SetConnectInfo(connectInfo)
+	}
+	if err := prepareDBCommander(conf, dbCommander); err != nil {
+		return nil, nil, err
+	}
+	return conf, dbCommander, nil
+}
+
+// readHostsFile reads the targets of a hosts file. Empty lines and lines starting with # are skipped.
+func readHostsFile(p, profile string) ([]fanoutTarget, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open hosts file: %w", err)
+	}
+	defer f.Close()
+
+	targets := []fanoutTarget{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		expanded, err := expandHostPattern(line)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range expanded {
+			hostPort, dbName, _ := strings.Cut(v, "/")
+			host, port := hostPort, ""
+			if i := strings.LastIndex(hostPort, ":"); i >= 0 {
+				host, port = hostPort[:i], hostPort[i+1:]
+			}
+			if host == "" {
+				return nil, fmt.Errorf("invalid host in hosts file: %s", v)
+			}
+			targets = append(targets, fanoutTarget{source: v, profile: profile, host: host, port: port, dbName: dbName})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read hosts file: %w", err)
+	}
+	return targets, nil
+}
+
+// expandHostPattern expands the braces of the pattern like a shell: shard-{01..03} to shard-01, shard-02 and shard-03,
+// and {eu,us}-db to eu-db and us-db. A range keeps the zero padding of its start.
+func expandHostPattern(pattern string) ([]string, error) {
+	start := strings.Index(pattern, "{")
+	if start < 0 {
+		return []string{pattern}, nil
+	}
+	end := strings.Index(pattern[start:], "}")
+	if end < 0 {
+		return nil, fmt.Errorf("unclosed { in %s", pattern)
+	}
+	end += start
+
+	body := pattern[start+1 : end]
+	var items []string
+	if from, to, found := strings.Cut(body, ".."); found {
+		first, err1 := strconv.Atoi(from)
+		last, err2 := strconv.Atoi(to)
+		if err1 != nil || err2 != nil || first > last {
+			return nil, fmt.Errorf("invalid range {%s} in %s", body, pattern)
+		}
+		width := 0
+		if len(from) > 1 && from[0] == '0' {
+			width = len(from)
+		}
+		for n := first; n <= last; n++ {
+			items = append(items, fmt.Sprintf("%0*d", width, n))
+		}
+	} else {
+		items = strings.Split(body, ",")
+	}
+
+	rests, err := expandHostPattern(pattern[end+1:])
+	if err != nil {
+		return nil, err
+	}
+	expanded := []string{}
+	for _, item := range items {
+		for _, rest := range rests {
+			expanded = append(expanded, pattern[:start]+item+rest)
+		}
+	}
+	return expanded, nil
+}
+
+// parseFanoutOutput splits the output into the header and the rows. The header lines that the client repeats
+// for each statement are skipped. Without a separator, every line of the output is a row of an output column.
+func parseFanoutOutput(out string, separator rune) ([]string, [][]string, error) {
+	out = strings.TrimRight(out, "\n")
+	if separator == 0 {
+		rows := [][]string{}
+		for _, line := range strings.Split(out, "\n") {
+			if line != "" {
+				rows = append(rows, []string{line})
+			}
+		}
+		return []string{"output"}, rows, nil
+	}
+
+	var records [][]string
+	if separator == '\t' {
+		for _, line := range strings.Split(out, "\n") {
+			if line != "" {
+				records = append(records, strings.Split(line, "\t"))
+			}
+		}
+	} else {
+		r := csv.NewReader(strings.NewReader(out))
+		r.Comma = separator
+		r.FieldsPerRecord = -1
+		r.LazyQuotes = true
+		var err error
+		records, err = r.ReadAll()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse output: %w", err)
+		}
+	}
+	if len(records) == 0 {
+		return nil, nil, nil
+	}
+	header, rows := records[0], [][]string{}
+	for _, record := range records[1:] {
+		if !slices.Equal(record, header) {
+			rows = append(rows, record)
+		}
+	}
+	return header, rows, nil
+}
+
+// writeFanoutResults writes the rows of all targets as one table, whose columns are the source and the union
+// of the columns of the targets in the order they appear.
+func writeFanoutResults(w io.Writer, format string, targets []fanoutTarget, results []fanoutResult) error {
+	columns := []string{"source"}
+	for _, r := range results {
+		for _, column := range r.header {
+			if !slices.Contains(columns, column) {
+				columns = append(columns, column)
+			}
+		}
+	}
+	rows := [][]string{}
+	for i, r := range results {
+		for _, record := range r.rows {
+			row := make([]string, len(columns))
+			row[0] = targets[i].source
+			for j, v := range record {
+				if j < len(r.header) {
+					row[slices.Index(columns, r.header[j])] = v
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(columns)
+		cw.WriteAll(rows)
+		return cw.Error()
+	case "json":
+		objects := []map[string]string{}
+		for _, row := range rows {
+			object := map[string]string{}
+			for j, v := range row {
+				object[columns[j]] = v
+			}
+			objects = append(objects, object)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(objects)
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(columns, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+}
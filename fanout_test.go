@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExpandHostPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+		wantErr bool
+	}{
+		{pattern: "db.example.com", want: []string{"db.example.com"}},
+		{pattern: "shard-{01..03}", want: []string{"shard-01", "shard-02", "shard-03"}},
+		{pattern: "shard-{8..10}", want: []string{"shard-8", "shard-9", "shard-10"}},
+		{pattern: "{eu,us}-db:3307/app", want: []string{"eu-db:3307/app", "us-db:3307/app"}},
+		{pattern: "{eu,us}-{1..2}", want: []string{"eu-1", "eu-2", "us-1", "us-2"}},
+		{pattern: "shard-{01..03", wantErr: true},
+		{pattern: "shard-{3..1}", wantErr: true},
+		{pattern: "shard-{a..c}", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := expandHostPattern(tt.pattern)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("expandHostPattern(%q) error = %v, want error %v", tt.pattern, err, tt.wantErr)
+			continue
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("expandHostPattern(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestParseFanoutOutput(t *testing.T) {
+	tests := []struct {
+		name       string
+		out        string
+		separator  rune
+		wantHeader []string
+		wantRows   [][]string
+	}{
+		{name: "tsv", out: "id\tname\n1\ta\n2\tb\n", separator: '\t', wantHeader: []string{"id", "name"}, wantRows: [][]string{{"1", "a"}, {"2", "b"}}},
+		{name: "repeated header", out: "id\tname\n1\ta\nid\tname\n2\tb\n", separator: '\t', wantHeader: []string{"id", "name"}, wantRows: [][]string{{"1", "a"}, {"2", "b"}}},
+		{name: "csv with quotes", out: "id,note\n1,\"a, b\"\n2,\"multi\nline\"\n", separator: ',', wantHeader: []string{"id", "note"}, wantRows: [][]string{{"1", "a, b"}, {"2", "multi\nline"}}},
+		{name: "header only", out: "id,note\n", separator: ',', wantHeader: []string{"id", "note"}, wantRows: [][]string{}},
+		{name: "empty", out: "", separator: ',', wantHeader: nil, wantRows: nil},
+		{name: "no separator", out: "line 1\n\nline 2\n", separator: 0, wantHeader: []string{"output"}, wantRows: [][]string{{"line 1"}, {"line 2"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, rows, err := parseFanoutOutput(tt.out, tt.separator)
+			if err != nil {
+				t.Fatalf("parseFanoutOutput() error = %v", err)
+			}
+			if strings.Join(header, "|") != strings.Join(tt.wantHeader, "|") {
+				t.Errorf("header = %q, want %q", header, tt.wantHeader)
+			}
+			if formatRows(rows) != formatRows(tt.wantRows) {
+				t.Errorf("rows = %q, want %q", rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func formatRows(rows [][]string) string {
+	lines := []string{}
+	for _, row := range rows {
+		lines = append(lines, strings.Join(row, "|"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestWriteFanoutResults(t *testing.T) {
+	targets := []fanoutTarget{{source: "eu"}, {source: "us"}, {source: "ap"}}
+	results := []fanoutResult{
+		{header: []string{"id", "name"}, rows: [][]string{{"1", "a"}}},
+		{header: []string{"name", "region"}, rows: [][]string{{"b", "us-east-1"}, {"c", "us-west-2"}}},
+		{err: errors.New("interrupted")},
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "csv", want: "source,id,name,region\neu,1,a,\nus,,b,us-east-1\nus,,c,us-west-2\n"},
+		{format: "table", want: "source  id  name  region\neu      1   a     \nus          b     us-east-1\nus          c     us-west-2\n"},
+		{format: "json", want: `[
+  {
+    "id": "1",
+    "name": "a",
+    "region": "",
+    "source": "eu"
+  },
+  {
+    "id": "",
+    "name": "b",
+    "region": "us-east-1",
+    "source": "us"
+  },
+  {
+    "id": "",
+    "name": "c",
+    "region": "us-west-2",
+    "source": "us"
+  }
+]
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := writeFanoutResults(&b, tt.format, targets, results); err != nil {
+				t.Fatalf("writeFanoutResults() error = %v", err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("writeFanoutResults() =\n%s\nwant\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// podHistoryFile is where the client keeps its history in the container.
+const podHistoryFile = "/tmp/.podsql-history"
+
+// maxHistoryLines bounds the merged history file.
+const maxHistoryLines = 5000
+
+// libeditHistoryHeader is the first line of the history files written by libedit, which some builds of mysql and psql use.
+const libeditHistoryHeader = "_HiStOrY_V2_"
+
+// HistoryKeeper is implemented by DBCommanders whose client reads its history from the file in an environment variable.
+type HistoryKeeper interface {
+	HistoryEnv() string
+}
+
+// HistoryConfig keeps the history of interactive clients across pods, per engine and profile.
+type HistoryConfig struct {
+	Disabled bool `yaml:"disabled"`
+	// Dir is the local directory of the history files, ~/.local/state/podsql/history by default
+	Dir string `yaml:"dir"`
+}
+
+// clientHistory is the local history file of a session, and the content it had when it was copied into the pod.
+type clientHistory struct {
+	env      string
+	local    string
+	snapshot []string
+}
+
+// newClientHistory returns the history of the engine and profile, or nil if the client does not keep a history.
+func newClientHistory(conf *Config, dbCommander DBCommander) (*clientHistory, error) {
+	keeper, ok := dbCommander.(HistoryKeeper)
+	if !ok || conf.History.Disabled {
+		return nil, nil
+	}
+	dir := expandHome(conf.History.Dir)
+	if dir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(homeDir, ".local", "state", "podsql", "history")
+	}
+	profile := conf.ProfileName
+	if profile == "" {
+		profile = "default"
+	}
+	h := &clientHistory{
+		env:   keeper.HistoryEnv(),
+		local: filepath.Join(dir, fmt.Sprintf("%s-%s.history", dbCommander.CommandType().CommandName(), profile)),
+	}
+	lines, err := readHistoryLines(h.local)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	h.snapshot = lines
+	return h, nil
+}
+
+func readHistoryLines(p string) ([]string, error) {
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
+
+// podFiles returns the history to copy into the container before the client starts.
+func (h *clientHistory) podFiles() []podFile {
+	if h == nil || len(h.snapshot) == 0 {
+		return nil
+	}
+	return []podFile{{Path: podHistoryFile, Data: []byte(strings.Join(h.snapshot, "\n") + "\n")}}
+}
+
+// wrapCommand points the client to the history file in the container.
+func (h *clientHistory) wrapCommand(command string) string {
+	if h == nil {
+		return command
+	}
+	return fmt.Sprintf("export %s=%s; %s", h.env, podHistoryFile, command)
+}
+
+// save copies the history back from the container and appends the new entries to the local file,
+// which may have been updated by other sessions in the meantime.
+func (h *clientHistory) save(ctx context.Context, clientset kubernetes.Interface, config *rest.Config, namespace, podName, container string) error {
+	if h == nil {
+		return nil
+	}
+	tmpDir, err := os.MkdirTemp("", "podsql-history")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	tmp := filepath.Join(tmpDir, "history")
+	if err := copyFileFromPod(ctx, clientset, config, namespace, podName, container, FileMapping{Remote: podHistoryFile, Local: tmp}); err != nil {
+		return err
+	}
+	remote, err := readHistoryLines(tmp)
+	if err != nil {
+		return err
+	}
+	added := newHistoryLines(h.snapshot, remote)
+	if len(added) == 0 {
+		return nil
+	}
+
+	current, err := readHistoryLines(h.local)
+	if err != nil {
+		return err
+	}
+	if len(current) == 0 && len(remote) > 0 && remote[0] == libeditHistoryHeader {
+		current = []string{libeditHistoryHeader}
+	}
+	merged := append(current, added...)
+	if len(merged) > maxHistoryLines {
+		start := len(merged) - maxHistoryLines
+		if merged[0] == libeditHistoryHeader {
+			merged = append([]string{libeditHistoryHeader}, merged[start+1:]...)
+		} else {
+			merged = merged[start:]
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(h.local), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(h.local, []byte(strings.Join(merged, "\n")+"\n"), 0600)
+}
+
+// newHistoryLines returns the lines the session added to the history.
+// The client may have dropped old lines from the top of the file, so the longest suffix of the snapshot
+// that the remote file starts with is skipped.
+func newHistoryLines(snapshot, remote []string) []string {
+	if len(remote) > 0 && remote[0] == libeditHistoryHeader {
+		remote = remote[1:]
+		if len(snapshot) > 0 && snapshot[0] == libeditHistoryHeader {
+			snapshot = snapshot[1:]
+		}
+	}
+	for n := min(len(snapshot), len(remote)); n > 0; n-- {
+		if slices.Equal(snapshot[len(snapshot)-n:], remote[:n]) {
+			return remote[n:]
+		}
+	}
+	return remote
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// The token is signed locally with the AWS credentials of the user
+	iamAuthLocal = "local"
+	// The token is generated in the pod with the credentials of its ServiceAccount (IRSA or Pod Identity)
+	iamAuthPod = "pod"
+
+	iamAuthTokenDir   = "/podsql-auth"
+	iamAuthTokenFile  = iamAuthTokenDir + "/token"
+	iamAuthCLIImage   = "amazon/aws-cli:2.15.0"
+	rdsAuthTokenValid = 15 * time.Minute
+)
+
+// RDSAuthTokenBuilder generates an authentication token for Amazon RDS IAM database authentication.
+type RDSAuthTokenBuilder interface {
+	BuildAuthToken(endpoint, region, user string) (string, error)
+}
+
+type sigV4RDSAuthTokenBuilder struct {
+	credentials func() (AWSCredentials, error)
+	now         func() time.Time
+}
+
+func (b *sigV4RDSAuthTokenBuilder) BuildAuthToken(endpoint, region, user string) (string, error) {
+	creds, err := b.credentials()
+	if err != nil {
+		return "", fmt.Errorf("failed to load AWS credentials: %w", err)
+	}
+	query := url.Values{}
+	query.Set("Action", "connect")
+	query.Set("DBUser", user)
+	return fmt.Sprintf("%s/?%s", endpoint, presignSigV4(creds, endpoint, region, "rds-db", query, rdsAuthTokenValid, b.now())), nil
+}
+
+// rdsAuthTokenBuilder can be replaced with a stub in tests.
+var rdsAuthTokenBuilder RDSAuthTokenBuilder = &sigV4RDSAuthTokenBuilder{
+	credentials: LoadAWSCredentials,
+	now:         time.Now,
+}
+
+// iamAuth holds the --iam-auth and --iam-region options of the mysql and psql subcommands.
+type iamAuth struct {
+	mode   string
+	region string
+}
+
+// parseArg consumes the IAM authentication options and reports whether args[*i] was one of them.
+func (a *iamAuth) parseArg(args []string, i *int) bool {
+	arg := args[*i]
+	switch {
+	case arg == "--iam-auth":
+		a.mode = iamAuthLocal
+	case strings.HasPrefix(arg, "--iam-auth="):
+		a.mode = strings.TrimPrefix(arg, "--iam-auth=")
+	case arg == "--iam-region":
+		*i++
+		a.region = args[*i]
+	case strings.HasPrefix(arg, "--iam-region="):
+		a.region = strings.TrimPrefix(arg, "--iam-region=")
+	default:
+		return false
+	}
+	return true
+}
+
+func (a *iamAuth) enabled() bool {
+	return a.mode != ""
+}
+
+// resolve validates the options and, in local mode, replaces the password with a generated token.
+func (a *iamAuth) resolve(connectInfo *ConnectInfo) error {
+	if !a.enabled() {
+		return nil
+	}
+	if a.mode != iamAuthLocal && a.mode != iamAuthPod {
+		return fmt.Errorf("unsupported --iam-auth mode: %s", a.mode)
+	}
+	if connectInfo.Server == "" || connectInfo.User == "" {
+		return fmt.Errorf("host and user are required for IAM authentication")
+	}
+	if a.region == "" {
+		a.region = AWSRegion()
+	}
+	if a.region == "" {
+		a.region = rdsRegionOfHost(connectInfo.Server)
+	}
+	if a.region == "" {
+		return fmt.Errorf("region is required for IAM authentication (--iam-region)")
+	}
+
+	if a.mode == iamAuthPod {
+		connectInfo.Password = ""
+		return nil
+	}
+	token, err := rdsAuthTokenBuilder.BuildAuthToken(net.JoinHostPort(connectInfo.Server, connectInfo.Port), a.region, connectInfo.User)
+	if err != nil {
+		return fmt.Errorf("failed to generate IAM authentication token: %w", err)
+	}
+	connectInfo.Password = token
+	return nil
+}
+
+// rdsRegionOfHost returns the region of an RDS endpoint such as db.xxxx.ap-northeast-1.rds.amazonaws.com.
+func rdsRegionOfHost(host string) string {
+	parts := strings.Split(host, ".")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] == "rds" {
+			return parts[i-1]
+		}
+	}
+	return ""
+}
+
+// passwordEnv returns the assignment that overrides the password environment variable with the token generated in the pod.
+func (a *iamAuth) passwordEnv(name string) string {
+	if a.mode != iamAuthPod {
+		return ""
+	}
+	return fmt.Sprintf("%s=\"$(cat %s)\" ", name, iamAuthTokenFile)
+}
+
+// customizePod adds the init container that generates the token with the ServiceAccount of the pod.
+func (a *iamAuth) customizePod(pod *corev1.Pod, connectInfo ConnectInfo) {
+	if a.mode != iamAuthPod {
+		return
+	}
+
+	volume := corev1.Volume{
+		Name:         "iam-auth-volume",
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory}},
+	}
+	mount := corev1.VolumeMount{Name: volume.Name, MountPath: iamAuthTokenDir}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, volume)
+	pod.Spec.InitContainers = append(pod.Spec.InitContainers, corev1.Container{
+		Name:  "iam-auth",
+		Image: iamAuthCLIImage,
+		Command: []string{"/bin/sh", "-c", fmt.Sprintf(
+			"aws rds generate-db-auth-token --hostname \"%s\" --port \"%s\" --username \"%s\" --region \"%s\" > %s",
+			connectInfo.Server, connectInfo.Port, connectInfo.User, a.region, iamAuthTokenFile)},
+		VolumeMounts: []corev1.VolumeMount{mount},
+	})
+	for i := range pod.Spec.Containers {
+		pod.Spec.Containers[i].VolumeMounts = append(pod.Spec.Containers[i].VolumeMounts, mount)
+	}
+}
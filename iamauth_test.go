@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+type stubRDSAuthTokenBuilder struct {
+	endpoint, region, user string
+}
+
+func (b *stubRDSAuthTokenBuilder) BuildAuthToken(endpoint, region, user string) (string, error) {
+	b.endpoint, b.region, b.user = endpoint, region, user
+	return "stub-token", nil
+}
+
+func stubRDSAuthToken(t *testing.T) *stubRDSAuthTokenBuilder {
+	t.Helper()
+	stub := &stubRDSAuthTokenBuilder{}
+	orig := rdsAuthTokenBuilder
+	rdsAuthTokenBuilder = stub
+	t.Cleanup(func() { rdsAuthTokenBuilder = orig })
+	return stub
+}
+
+func TestIAMAuthResolve(t *testing.T) {
+	const host = "db.abc123.ap-northeast-1.rds.amazonaws.com"
+	tests := []struct {
+		name         string
+		auth         iamAuth
+		envRegion    string
+		wantRegion   string
+		wantPassword string
+		wantErr      string
+	}{
+		{name: "region of the host", auth: iamAuth{mode: iamAuthLocal}, wantRegion: "ap-northeast-1", wantPassword: "stub-token"},
+		{name: "region of the environment", auth: iamAuth{mode: iamAuthLocal}, envRegion: "us-east-1", wantRegion: "us-east-1", wantPassword: "stub-token"},
+		{name: "region of the option", auth: iamAuth{mode: iamAuthLocal, region: "eu-west-1"}, envRegion: "us-east-1", wantRegion: "eu-west-1", wantPassword: "stub-token"},
+		{name: "pod mode", auth: iamAuth{mode: iamAuthPod}, wantRegion: "ap-northeast-1", wantPassword: ""},
+		{name: "unsupported mode", auth: iamAuth{mode: "kms"}, wantErr: "unsupported --iam-auth mode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AWS_REGION", tt.envRegion)
+			t.Setenv("AWS_DEFAULT_REGION", "")
+			stub := stubRDSAuthToken(t)
+			connectInfo := ConnectInfo{Server: host, Port: "5432", User: "app", Password: "secret"}
+
+			err := tt.auth.resolve(&connectInfo)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolve() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolve() error = %v", err)
+			}
+			if tt.auth.region != tt.wantRegion {
+				t.Errorf("region = %q, want %q", tt.auth.region, tt.wantRegion)
+			}
+			if connectInfo.Password != tt.wantPassword {
+				t.Errorf("password = %q, want %q", connectInfo.Password, tt.wantPassword)
+			}
+			if tt.auth.mode == iamAuthLocal && (stub.endpoint != host+":5432" || stub.region != tt.wantRegion || stub.user != "app") {
+				t.Errorf("BuildAuthToken(%q, %q, %q)", stub.endpoint, stub.region, stub.user)
+			}
+		})
+	}
+}
+
+func TestIAMAuthResolveWithoutRegion(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	stubRDSAuthToken(t)
+	a := iamAuth{mode: iamAuthLocal}
+	err := a.resolve(&ConnectInfo{Server: "db.example.com", Port: "3306", User: "app"})
+	if err == nil || !strings.Contains(err.Error(), "--iam-region") {
+		t.Fatalf("resolve() error = %v, want the missing region", err)
+	}
+}
+
+func TestIAMAuthSSLArgs(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	stubRDSAuthToken(t)
+	const host = "db.abc123.ap-northeast-1.rds.amazonaws.com"
+
+	psql, err := NewPostgresCommander([]string{"-h", host, "-U", "app", "-d", "app", "--iam-auth", "-c", "SELECT 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command := psql.Command(); !strings.Contains(command, "sslmode='require'") {
+		t.Errorf("psql command %q does not require SSL", command)
+	}
+	if psql.ConnectInfo().Password != "stub-token" {
+		t.Errorf("psql password = %q, want the token", psql.ConnectInfo().Password)
+	}
+
+	psql, err = NewPostgresCommander([]string{"-h", host, "-U", "app", "-d", "sslmode=verify-full dbname=app", "--iam-auth", "-c", "SELECT 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command := psql.Command(); strings.Contains(command, "sslmode='require'") {
+		t.Errorf("psql command %q overrides the sslmode of the user", command)
+	}
+
+	mysql, err := NewMysqlCommander([]string{"--no-defaults", "--flavor=mysql", "-h", host, "-u", "app", "--iam-auth", "-e", "SELECT 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command := mysql.Command(); !strings.Contains(command, "--enable-cleartext-plugin") {
+		t.Errorf("mysql command %q does not enable the cleartext plugin", command)
+	}
+}
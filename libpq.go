@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// pgEnvOptions are the environment variables of libpq for the connection parameters other than ConnectInfo.
+// The ones pointing to local files, such as PGSSLROOTCERT, are left out because the files are not in the pod.
+var pgEnvOptions = map[string]string{
+	"PGSSLMODE":            "sslmode",
+	"PGCONNECT_TIMEOUT":    "connect_timeout",
+	"PGAPPNAME":            "application_name",
+	"PGOPTIONS":            "options",
+	"PGTARGETSESSIONATTRS": "target_session_attrs",
+	"PGCLIENTENCODING":     "client_encoding",
+}
+
+// isConnInfoString reports whether the dbname is a connection string or a URI, which libpq expands.
+func isConnInfoString(s string) bool {
+	return strings.Contains(s, "=") || strings.HasPrefix(s, "postgresql://") || strings.HasPrefix(s, "postgres://")
+}
+
+// parseConnInfo parses a libpq connection string such as host=db dbname='my db' sslmode=require.
+// Values may be single-quoted, and \' and \\ are unescaped.
+func parseConnInfo(s string) (map[string]string, error) {
+	params := map[string]string{}
+	for i := 0; i < len(s); {
+		for i < len(s) && isConnInfoSpace(s[i]) {
+			i++
+		}
+		if i == len(s) {
+			break
+		}
+
+		start := i
+		for i < len(s) && s[i] != '=' && !isConnInfoSpace(s[i]) {
+			i++
+		}
+		key := s[start:i]
+		for i < len(s) && isConnInfoSpace(s[i]) {
+			i++
+		}
+		if i == len(s) || s[i] != '=' {
+			return nil, fmt.Errorf("missing \"=\" after %q in connection info string", key)
+		}
+		i++
+		for i < len(s) && isConnInfoSpace(s[i]) {
+			i++
+		}
+
+		var value strings.Builder
+		if i < len(s) && s[i] == '\'' {
+			i++
+			closed := false
+			for i < len(s) {
+				if s[i] == '\\' && i+1 < len(s) {
+					value.WriteByte(s[i+1])
+					i += 2
+					continue
+				}
+				if s[i] == '\'' {
+					i++
+					closed = true
+					break
+				}
+				value.WriteByte(s[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated quoted string in connection info string")
+			}
+		} else {
+			for i < len(s) && !isConnInfoSpace(s[i]) {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				value.WriteByte(s[i])
+				i++
+			}
+		}
+		params[key] = value.String()
+	}
+	return params, nil
+}
+
+func isConnInfoSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+// readPGService returns the parameters of the service in the service files.
+// Like libpq, the user file (PGSERVICEFILE or ~/.pg_service.conf) is searched first,
+// and the system file in PGSYSCONFDIR only if the service is not found there.
+func readPGService(name string) (map[string]string, error) {
+	files := []string{}
+	if p := os.Getenv("PGSERVICEFILE"); p != "" {
+		files = append(files, p)
+	} else if homeDir, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(homeDir, ".pg_service.conf"))
+	}
+	if dir := os.Getenv("PGSYSCONFDIR"); dir != "" {
+		files = append(files, filepath.Join(dir, "pg_service.conf"))
+	}
+
+	for _, f := range files {
+		params, err := readINISection(f, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f, err)
+		}
+		if len(params) > 0 {
+			return params, nil
+		}
+	}
+	return nil, fmt.Errorf("definition of service %q not found", name)
+}
+
+// pgPassFile returns the password file given by passfile, PGPASSFILE or ~/.pgpass.
+func pgPassFile(passFile string) string {
+	if passFile != "" {
+		return passFile
+	}
+	if p := os.Getenv("PGPASSFILE"); p != "" {
+		return p
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".pgpass")
+}
+
+// lookupPGPass returns the password of the first line matching the connection in the password file.
+// Like libpq, malformed lines are skipped, and the file is ignored with a warning if others can read it.
+func lookupPGPass(p, host, port, dbname, user string) (string, error) {
+	if p == "" {
+		return "", nil
+	}
+	fi, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if !fi.Mode().IsRegular() {
+		fmt.Fprintf(os.Stderr, "WARNING: password file \"%s\" is not a plain file\n", p)
+		return "", nil
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm()&0077 != 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: password file \"%s\" has group or world access; permissions should be u=rw (0600) or less\n", p)
+		return "", nil
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+
+	// A unix socket connection matches localhost
+	if host == "" || strings.HasPrefix(host, "/") {
+		host = "localhost"
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := splitPGPassLine(line)
+		if len(fields) != 5 {
+			continue
+		}
+		if matchPGPassField(fields[0], host) && matchPGPassField(fields[1], port) &&
+			matchPGPassField(fields[2], dbname) && matchPGPassField(fields[3], user) {
+			return unescapePGPass(fields[4]), nil
+		}
+	}
+	return "", nil
+}
+
+// splitPGPassLine splits the line at the first four unescaped colons, keeping the escapes.
+// The rest of the line is the password, which may contain colons.
+func splitPGPassLine(line string) []string {
+	fields := []string{}
+	start := 0
+	for i := 0; i < len(line) && len(fields) < 4; i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case ':':
+			fields = append(fields, line[start:i])
+			start = i + 1
+		}
+	}
+	return append(fields, line[start:])
+}
+
+func matchPGPassField(field, value string) bool {
+	return field == "*" || unescapePGPass(field) == value
+}
+
+func unescapePGPass(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseConnInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "plain", s: "host=db port=5433 dbname=app", want: map[string]string{"host": "db", "port": "5433", "dbname": "app"}},
+		{name: "spaces around =", s: "host = db  sslmode =require", want: map[string]string{"host": "db", "sslmode": "require"}},
+		{name: "quoted", s: `dbname='my db' options='-c search_path=app'`, want: map[string]string{"dbname": "my db", "options": "-c search_path=app"}},
+		{name: "escapes in quotes", s: `password='it\'s \\ here'`, want: map[string]string{"password": `it's \ here`}},
+		{name: "escapes without quotes", s: `password=a\ b\\c`, want: map[string]string{"password": `a b\c`}},
+		{name: "empty value", s: "password='' host=db", want: map[string]string{"password": "", "host": "db"}},
+		{name: "missing =", s: "host db", wantErr: true},
+		{name: "unterminated quote", s: "dbname='app", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseConnInfo(tt.s)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseConnInfo(%q) = %v, want an error", tt.s, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseConnInfo(%q) error = %v", tt.s, err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("parseConnInfo(%q) = %v, want %v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapeConnInfoValue(t *testing.T) {
+	for _, v := range []string{"plain", "my db", `it's`, `back\slash`, `\'`} {
+		params, err := parseConnInfo("dbname='" + escapeConnInfoValue(v) + "'")
+		if err != nil {
+			t.Fatalf("parseConnInfo() error = %v", err)
+		}
+		if params["dbname"] != v {
+			t.Errorf("round trip of %q = %q", v, params["dbname"])
+		}
+	}
+}
+
+func TestSplitPGPassLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{line: "db:5432:app:admin:secret", want: []string{"db", "5432", "app", "admin", "secret"}},
+		{line: "*:*:*:admin:pass:with:colons", want: []string{"*", "*", "*", "admin", "pass:with:colons"}},
+		{line: `db\:1:5432:app:admin:secret`, want: []string{`db\:1`, "5432", "app", "admin", "secret"}},
+		{line: `db:5432:app:ad\\min:secret`, want: []string{"db", "5432", "app", `ad\\min`, "secret"}},
+		{line: "db:5432:app", want: []string{"db", "5432", "app"}},
+	}
+	for _, tt := range tests {
+		if got := splitPGPassLine(tt.line); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("splitPGPassLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestLookupPGPass(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "pgpass")
+	content := strings.Join([]string{
+		"# comment",
+		"malformed:line",
+		`db\:1:5432:app:admin:colon\:pass`,
+		"db:5432:app:admin:first",
+		"db:5432:app:admin:second",
+		"*:*:*:readonly:wild",
+		`localhost:5432:*:admin:local\\pass`,
+	}, "\n")
+	if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name                     string
+		host, port, dbname, user string
+		want                     string
+	}{
+		{name: "escaped colon in the host", host: "db:1", port: "5432", dbname: "app", user: "admin", want: "colon:pass"},
+		{name: "first match wins", host: "db", port: "5432", dbname: "app", user: "admin", want: "first"},
+		{name: "wildcards", host: "other", port: "6432", dbname: "x", user: "readonly", want: "wild"},
+		{name: "socket matches localhost", host: "/var/run/postgresql", port: "5432", dbname: "x", user: "admin", want: `local\pass`},
+		{name: "no match", host: "db", port: "5433", dbname: "app", user: "admin", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lookupPGPass(p, tt.host, tt.port, tt.dbname, tt.user)
+			if err != nil {
+				t.Fatalf("lookupPGPass() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("lookupPGPass() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if err := os.Chmod(p, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := lookupPGPass(p, "db", "5432", "app", "admin"); got != "" {
+		t.Errorf("lookupPGPass() of a world-readable file = %q, want it ignored", got)
+	}
+}
+
+func TestReadPGService(t *testing.T) {
+	dir := t.TempDir()
+	userFile := filepath.Join(dir, "user.conf")
+	os.WriteFile(userFile, []byte("[app]\nhost=user-db\nport=6432\n"), 0600)
+	os.Mkdir(filepath.Join(dir, "sys"), 0700)
+	os.WriteFile(filepath.Join(dir, "sys", "pg_service.conf"), []byte("[app]\nhost=sys-db\n[report]\nhost=report-db\ndbname=report\n"), 0600)
+	t.Setenv("PGSERVICEFILE", userFile)
+	t.Setenv("PGSYSCONFDIR", filepath.Join(dir, "sys"))
+
+	got, err := readPGService("app")
+	if err != nil || !maps.Equal(got, map[string]string{"host": "user-db", "port": "6432"}) {
+		t.Errorf("readPGService(app) = %v, %v", got, err)
+	}
+	got, err = readPGService("report")
+	if err != nil || !maps.Equal(got, map[string]string{"host": "report-db", "dbname": "report"}) {
+		t.Errorf("readPGService(report) = %v, %v", got, err)
+	}
+	if _, err := readPGService("missing"); err == nil {
+		t.Errorf("readPGService(missing) returned no error")
+	}
+}
+
+func TestPostgresConnectionResolution(t *testing.T) {
+	for _, env := range []string{"PGHOST", "PGPORT", "PGDATABASE", "PGUSER", "PGPASSWORD", "PGSERVICE", "PGSSLMODE", "PGOPTIONS"} {
+		t.Setenv(env, "")
+	}
+	dir := t.TempDir()
+	serviceFile := filepath.Join(dir, "pg_service.conf")
+	os.WriteFile(serviceFile, []byte("[app]\nhost=service-db\nport=6432\ndbname=app\nuser=svc\nsslmode=require\n"), 0600)
+	passFile := filepath.Join(dir, "pgpass")
+	os.WriteFile(passFile, []byte("service-db:6432:app:admin:from-pgpass\n"), 0600)
+	t.Setenv("PGSERVICEFILE", serviceFile)
+	t.Setenv("PGSYSCONFDIR", dir)
+	t.Setenv("PGPASSFILE", passFile)
+
+	m, err := NewPostgresCommander([]string{"-d", "service=app user=admin", "-P", "pager=off", "-c", "SELECT 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ConnectInfo{Server: "service-db", Port: "6432", User: "admin", Password: "from-pgpass", DbName: "app"}
+	if got := m.ConnectInfo(); got != want {
+		t.Errorf("ConnectInfo() = %+v, want %+v", got, want)
+	}
+	command := m.Command()
+	for _, part := range []string{`-P "pager=off"`, `"dbname='app' sslmode='require'"`} {
+		if !strings.Contains(command, part) {
+			t.Errorf("Command() = %s, want %s in it", command, part)
+		}
+	}
+}
@@ -35,12 +35,72 @@ func main() {
 				Usage:   "config file path",
 				Value:   defaultConfigPath,
 			},
+			&cli.StringFlag{
+				Name:  "service-account",
+				Usage: "service account of the pod, e.g. for IAM authentication with --iam-auth=pod",
+			},
+			&cli.StringFlag{
+				Name:  "profile",
+				Usage: "profile in the config file to use",
+			},
+			&cli.BoolFlag{
+				Name:  "read-only",
+				Usage: "start the session in read-only mode and reject queries that may write before creating the pod",
+			},
+			&cli.BoolFlag{
+				Name:    "yes",
+				Aliases: []string{"y"},
+				Usage:   "run destructive queries on protected profiles without asking for confirmation",
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "cancel the query on the server after the duration, e.g. 30s or 5m. interactive sessions only get the statement timeout",
+			},
+			&cli.StringFlag{
+				Name:  "record",
+				Usage: "record the interactive session to the file in the asciicast v2 format",
+			},
+			&cli.StringFlag{
+				Name:  "ssl-mode",
+				Usage: "TLS mode of the connection: disable, allow, prefer, require, verify-ca or verify-full",
+			},
+			&cli.StringFlag{
+				Name:  "ssl-ca",
+				Usage: "local CA certificate file to verify the server, mounted into the pod",
+			},
+			&cli.StringFlag{
+				Name:  "ssl-cert",
+				Usage: "local client certificate file, mounted into the pod",
+			},
+			&cli.StringFlag{
+				Name:  "ssl-key",
+				Usage: "local client key file, mounted into the pod",
+			},
+			&cli.StringSliceFlag{
+				Name:  "copy-in",
+				Usage: "copy a local file into the pod before the client starts, in the form <local-path>:<remote-path>. relative remote paths are placed under /data",
+			},
+			&cli.StringSliceFlag{
+				Name:  "copy-out",
+				Usage: "copy a file from the pod to the local machine after a non-interactive command finishes, in the form <remote-path>:<local-path>",
+			},
 		},
 		// Subcommands
 		Commands: []*cli.Command{
 			MysqlCommands(),
 			SQLServerCommands(),
 			PostgresCommands(),
+			OracleCommands(),
+			MongoCommands(),
+			RedisCommands(),
+			ClickHouseCommands(),
+			ConnectCommands(),
+			DumpCommands(),
+			RestoreCommands(),
+			ReplayCommands(),
+			RequestAccessCommands(),
+			ApproveCommands(),
+			FanoutCommands(),
 		},
 	}
 
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+type MongoCommander struct {
+	originalArgs []string
+
+	escapedArgs []string
+	connectInfo ConnectInfo
+	// scheme and uriOptions are taken from the connection string, e.g. mongodb+srv and tls=true&retryWrites=false
+	scheme     string
+	uriOptions string
+	query      []string
+	tlsCAFile  string
+	tlsCA      string
+	podTLS     PodTLS
+	help       bool
+}
+
+func NewMongoCommander(args []string) (*MongoCommander, error) {
+	c := &MongoCommander{}
+	c.originalArgs = args
+	c.escapedArgs = make([]string, 0)
+	c.connectInfo = ConnectInfo{Port: "27017"}
+	c.scheme = "mongodb"
+	if err := c.parseArgs(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (m *MongoCommander) parseArgs(args []string) error {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-h" || arg == "--help":
+			m.help = true
+			break
+		case arg == "--host":
+			i++
+			m.parseHost(args[i])
+		case strings.HasPrefix(arg, "--host="):
+			m.parseHost(strings.TrimPrefix(arg, "--host="))
+		case arg == "--port":
+			i++
+			m.connectInfo.Port = args[i]
+		case strings.HasPrefix(arg, "--port="):
+			m.connectInfo.Port = strings.TrimPrefix(arg, "--port=")
+		case arg == "-u" || arg == "--username":
+			i++
+			m.connectInfo.User = args[i]
+		case strings.HasPrefix(arg, "--username="):
+			m.connectInfo.User = strings.TrimPrefix(arg, "--username=")
+		case arg == "-p" || arg == "--password":
+			i++
+			m.connectInfo.Password = args[i]
+		case strings.HasPrefix(arg, "--password="):
+			m.connectInfo.Password = strings.TrimPrefix(arg, "--password=")
+		case arg == "--eval":
+			i++
+			m.query = append(m.query, args[i])
+		case strings.HasPrefix(arg, "--eval="):
+			m.query = append(m.query, strings.TrimPrefix(arg, "--eval="))
+		case arg == "-f" || arg == "--file":
+			i++
+			if err := m.readScript(args[i]); err != nil {
+				return err
+			}
+		case strings.HasPrefix(arg, "--file="):
+			if err := m.readScript(strings.TrimPrefix(arg, "--file=")); err != nil {
+				return err
+			}
+		case arg == "--tlsCAFile":
+			i++
+			m.tlsCAFile = args[i]
+		case strings.HasPrefix(arg, "--tlsCAFile="):
+			m.tlsCAFile = strings.TrimPrefix(arg, "--tlsCAFile=")
+		case arg == "--url":
+			i++
+			if err := m.parseURI(args[i]); err != nil {
+				return err
+			}
+		case strings.HasPrefix(arg, "--url="):
+			if err := m.parseURI(strings.TrimPrefix(arg, "--url=")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(arg, "mongodb://") || strings.HasPrefix(arg, "mongodb+srv://"):
+			if err := m.parseURI(arg); err != nil {
+				return err
+			}
+		case strings.HasSuffix(arg, ".js") && !strings.HasPrefix(arg, "-"):
+			if err := m.readScript(arg); err != nil {
+				return err
+			}
+		case strings.HasPrefix(arg, "--") && strings.Contains(arg, "="):
+			k, v, _ := strings.Cut(arg, "=")
+			m.escapedArgs = append(m.escapedArgs, fmt.Sprintf("%s=\"%s\"", k, v))
+		case strings.HasPrefix(arg, "-"):
+			m.escapedArgs = append(m.escapedArgs, arg)
+		default:
+			// mongosh takes a database name or host/database as the address
+			host, db, found := strings.Cut(arg, "/")
+			if found {
+				m.parseHost(host)
+				m.connectInfo.DbName = db
+			} else {
+				m.connectInfo.DbName = arg
+			}
+		}
+	}
+
+	if m.tlsCAFile != "" {
+		data, err := os.ReadFile(m.tlsCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read --tlsCAFile: %w", err)
+		}
+		m.tlsCA = string(data)
+	}
+	return nil
+}
+
+// parseHost parses host[:port], which may also be a replica set like rs0/host1:27017,host2:27017.
+func (m *MongoCommander) parseHost(host string) {
+	if strings.Contains(host, ",") || strings.Contains(host, "/") {
+		m.connectInfo.Server = host
+		m.connectInfo.Port = ""
+		return
+	}
+	h, port, found := strings.Cut(host, ":")
+	m.connectInfo.Server = h
+	if found {
+		m.connectInfo.Port = port
+	}
+}
+
+// parseURI parses mongodb://[user:pass@]host1[:port1][,host2[:port2]...][/db][?options].
+// The credentials are moved to the Secret and the other parts are kept in the connection string.
+func (m *MongoCommander) parseURI(uri string) error {
+	scheme, rest, _ := strings.Cut(uri, "://")
+	m.scheme = scheme
+
+	rest, m.uriOptions, _ = strings.Cut(rest, "?")
+	hosts, db, _ := strings.Cut(rest, "/")
+	if i := strings.LastIndex(hosts, "@"); i >= 0 {
+		user, password, _ := strings.Cut(hosts[:i], ":")
+		var err error
+		if m.connectInfo.User, err = url.PathUnescape(user); err != nil {
+			return fmt.Errorf("invalid connection string: %w", err)
+		}
+		if m.connectInfo.Password, err = url.PathUnescape(password); err != nil {
+			return fmt.Errorf("invalid connection string: %w", err)
+		}
+		hosts = hosts[i+1:]
+	}
+
+	if strings.Contains(hosts, ",") || scheme == "mongodb+srv" {
+		m.connectInfo.Server = hosts
+		m.connectInfo.Port = ""
+	} else {
+		m.parseHost(hosts)
+	}
+	if db != "" {
+		m.connectInfo.DbName = db
+	}
+	return nil
+}
+
+func (m *MongoCommander) readScript(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	m.query = append(m.query, string(data))
+	return nil
+}
+
+func (m *MongoCommander) IsInteractive() bool {
+	return len(m.query) == 0 && !m.help
+}
+
+func (m *MongoCommander) ConnectInfo() ConnectInfo {
+	return m.connectInfo
+}
+
+func (m *MongoCommander) SetConnectInfo(connectInfo ConnectInfo) {
+	m.connectInfo = connectInfo
+}
+
+func (m *MongoCommander) Query() string {
+	return strings.Join(m.query, "\n")
+}
+
+func (m *MongoCommander) Files() map[string]string {
+	files := map[string]string{}
+	if m.tlsCA != "" {
+		files["ca.pem"] = m.tlsCA
+	}
+	return files
+}
+
+func (m *MongoCommander) HelpCommand() string {
+	return "mongosh --help"
+}
+
+// connectionString returns the connection string without the credentials.
+func (m *MongoCommander) connectionString() string {
+	hosts := m.connectInfo.Server
+	if m.connectInfo.Port != "" {
+		hosts = fmt.Sprintf("%s:%s", hosts, m.connectInfo.Port)
+	}
+	uri := fmt.Sprintf("%s://%s/%s", m.scheme, hosts, m.connectInfo.DbName)
+	if m.uriOptions != "" {
+		uri = fmt.Sprintf("%s?%s", uri, m.uriOptions)
+	}
+	return fmt.Sprintf("\"%s\"", uri)
+}
+
+func (m *MongoCommander) connectionArgs() []string {
+	connectionArgs := []string{
+		"mongosh",
+		m.connectionString(),
+	}
+	if m.connectInfo.User != "" {
+		connectionArgs = append(connectionArgs, "--username", "\"$SECRET_DB_USER\"", "--password", "\"$SECRET_DB_PASSWORD\"")
+	}
+	return append(connectionArgs, m.tlsArgs()...)
+}
+
+func (m *MongoCommander) ConfigureTLS(tls PodTLS) error {
+	m.podTLS = tls
+	return nil
+}
+
+// tlsArgs returns the TLS options. The CA file given by --ssl-ca takes precedence over --tlsCAFile.
+func (m *MongoCommander) tlsArgs() []string {
+	caFile := m.podTLS.CA
+	if caFile == "" && m.tlsCA != "" {
+		caFile = "/sql/ca.pem"
+	}
+
+	args := []string{}
+	switch m.podTLS.Mode {
+	case "disable", "allow", "prefer":
+		return args
+	case "require":
+		args = append(args, "--tls", "--tlsAllowInvalidCertificates")
+	case "verify-ca":
+		args = append(args, "--tls", "--tlsAllowInvalidHostnames")
+	default:
+		if m.podTLS.Mode == "" && caFile == "" && m.podTLS.CertKey == "" {
+			return args
+		}
+		args = append(args, "--tls")
+	}
+	if caFile != "" {
+		args = append(args, "--tlsCAFile", caFile)
+	}
+	if m.podTLS.CertKey != "" {
+		args = append(args, "--tlsCertificateKeyFile", m.podTLS.CertKey)
+	}
+	return args
+}
+
+func (m *MongoCommander) Command() string {
+	if m.help {
+		return m.HelpCommand()
+	}
+
+	return strings.Join(slices.Concat(m.connectionArgs(), []string{"--quiet"}, m.escapedArgs, []string{"--file", "/sql/query.sql"}), " ")
+}
+
+func (m *MongoCommander) InteractiveCommand() string {
+	return strings.Join(slices.Concat(m.connectionArgs(), m.escapedArgs), " ")
+}
+
+func (m *MongoCommander) ContainerImage() string {
+	return "mongo:7"
+}
+
+func (m *MongoCommander) SecretEnvKV() map[string]string {
+	return map[string]string{
+		"SECRET_DB_USER":     "username",
+		"SECRET_DB_PASSWORD": "password",
+	}
+}
+
+func (m *MongoCommander) CommandType() CommandType {
+	return MongoDB
+}
+
+func (m *MongoCommander) ParseResults(result string) []string {
+	return []string{}
+}
+
+func MongoCommands() *cli.Command {
+	return &cli.Command{
+		Name:            "mongosh",
+		Usage:           "execute mongosh commands",
+		ArgsUsage:       "<mongosh options>",
+		SkipFlagParsing: true,
+		Action:          executeMongoAction,
+	}
+}
+
+func executeMongoAction(c *cli.Context) error {
+	config, err := NewConfig(c)
+	if err != nil {
+		return err
+	}
+	podName, err := CreatePodName("podsql")
+	if err != nil {
+		return err
+	}
+	args := c.Args().Slice()
+	if len(args) == 0 {
+		args = []string{"--help"}
+	}
+	dbCommander, err := NewMongoCommander(args)
+	if err != nil {
+		return err
+	}
+	return runDBCommander(config, podName, dbCommander)
+}
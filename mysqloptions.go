@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// mysqlOptionFiles are the option files that the mysql client reads by default, in this order.
+// ~/.mylogin.cnf is read separately after them because it is encrypted.
+func mysqlOptionFiles(extraFile string) []string {
+	files := []string{"/etc/my.cnf", "/etc/mysql/my.cnf"}
+	if home := os.Getenv("MYSQL_HOME"); home != "" {
+		files = append(files, filepath.Join(home, "my.cnf"))
+	}
+	if extraFile != "" {
+		files = append(files, extraFile)
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(homeDir, ".my.cnf"))
+	}
+	return files
+}
+
+func mysqlLoginFile() string {
+	if p := os.Getenv("MYSQL_TEST_LOGIN_FILE"); p != "" {
+		return p
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".mylogin.cnf")
+}
+
+// mysqlOptionGroups returns the groups of the option files that the mysql client reads,
+// with the groups of --defaults-group-suffix appended.
+func mysqlOptionGroups(suffix string) []string {
+	groups := []string{"client", "mysql"}
+	if suffix != "" {
+		groups = append(groups, "client"+suffix, "mysql"+suffix)
+	}
+	return groups
+}
+
+// readMySQLOptionFile reads the options in the groups into values, where later options overwrite earlier ones.
+// The option names are normalized to use dashes. A file that does not exist is ignored.
+func readMySQLOptionFile(p string, groups []string, values map[string]string) error {
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return parseMySQLOptions(data, filepath.Dir(p), groups, values)
+}
+
+func parseMySQLOptions(data []byte, dir string, groups []string, values map[string]string) error {
+	inGroup := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "!includedir "):
+			includeDir := strings.TrimSpace(strings.TrimPrefix(line, "!includedir "))
+			files, err := filepath.Glob(filepath.Join(includeDir, "*.cnf"))
+			if err != nil {
+				return err
+			}
+			sort.Strings(files)
+			for _, f := range files {
+				if err := readMySQLOptionFile(f, groups, values); err != nil {
+					return err
+				}
+			}
+			continue
+		case strings.HasPrefix(line, "!include "):
+			include := strings.TrimSpace(strings.TrimPrefix(line, "!include "))
+			if !filepath.IsAbs(include) {
+				include = filepath.Join(dir, include)
+			}
+			if err := readMySQLOptionFile(include, groups, values); err != nil {
+				return err
+			}
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			inGroup = false
+			for _, g := range groups {
+				if strings.EqualFold(name, g) {
+					inGroup = true
+				}
+			}
+			continue
+		}
+		if !inGroup {
+			continue
+		}
+		k, v, _ := strings.Cut(line, "=")
+		k = strings.ReplaceAll(strings.TrimSpace(k), "_", "-")
+		values[k] = parseMySQLOptionValue(strings.TrimSpace(v))
+	}
+	return scanner.Err()
+}
+
+// parseMySQLOptionValue removes the quotes and the trailing comment of the value and interprets the escape sequences.
+func parseMySQLOptionValue(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') {
+		if end := strings.IndexByte(v[1:], v[0]); end >= 0 {
+			return unescapeMySQLOption(v[1 : end+1])
+		}
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = v[:i]
+	}
+	if i := strings.Index(v, "\t#"); i >= 0 {
+		v = v[:i]
+	}
+	return unescapeMySQLOption(strings.TrimSpace(v))
+}
+
+func unescapeMySQLOption(v string) string {
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		if v[i] != '\\' || i+1 == len(v) {
+			b.WriteByte(v[i])
+			continue
+		}
+		i++
+		switch v[i] {
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 's':
+			b.WriteByte(' ')
+		case '\\':
+			b.WriteByte('\\')
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(v[i])
+		}
+	}
+	return b.String()
+}
+
+// decryptMySQLLoginFile decrypts the obfuscated login path file written by mysql_config_editor.
+// The file starts with 4 unused bytes and a 20 bytes key, followed by the lines encrypted with AES-128-ECB,
+// each prefixed with its length in 4 bytes little endian.
+func decryptMySQLLoginFile(data []byte) ([]byte, error) {
+	const headerLen = 24
+	if len(data) < headerLen {
+		return nil, fmt.Errorf("login path file is too short")
+	}
+	key := make([]byte, 16)
+	for i, b := range data[4:headerLen] {
+		key[i%16] ^= b
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var plain bytes.Buffer
+	for rest := data[headerLen:]; len(rest) > 0; {
+		if len(rest) < 4 {
+			return nil, fmt.Errorf("login path file is truncated")
+		}
+		n := int(binary.LittleEndian.Uint32(rest[:4]))
+		rest = rest[4:]
+		if n > len(rest) || n%aes.BlockSize != 0 {
+			return nil, fmt.Errorf("login path file is truncated")
+		}
+		line := make([]byte, n)
+		for i := 0; i < n; i += aes.BlockSize {
+			block.Decrypt(line[i:i+aes.BlockSize], rest[i:i+aes.BlockSize])
+		}
+		rest = rest[n:]
+		// PKCS#7 padding
+		if n > 0 {
+			pad := int(line[n-1])
+			if pad == 0 || pad > aes.BlockSize || pad > n {
+				return nil, fmt.Errorf("invalid padding in login path file")
+			}
+			line = line[:n-pad]
+		}
+		plain.Write(line)
+	}
+	return plain.Bytes(), nil
+}
+
+// readMySQLLoginFile reads the groups of the login path file, if it exists.
+func readMySQLLoginFile(p string, groups []string, values map[string]string) error {
+	if p == "" {
+		return nil
+	}
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	plain, err := decryptMySQLLoginFile(data)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", p, err)
+	}
+	return parseMySQLOptions(plain, filepath.Dir(p), groups, values)
+}
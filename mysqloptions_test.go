@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// encryptMySQLLoginFile obfuscates the lines the way mysql_config_editor writes ~/.mylogin.cnf.
+func encryptMySQLLoginFile(t *testing.T, lines []string) []byte {
+	t.Helper()
+	rawKey := []byte("0123456789abcdefghij")
+	key := make([]byte, 16)
+	for i, b := range rawKey {
+		key[i%16] ^= b
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := append([]byte{0, 0, 0, 0}, rawKey...)
+	for _, line := range lines {
+		plain := []byte(line + "\n")
+		pad := aes.BlockSize - len(plain)%aes.BlockSize
+		plain = append(plain, bytes.Repeat([]byte{byte(pad)}, pad)...)
+		encrypted := make([]byte, len(plain))
+		for i := 0; i < len(plain); i += aes.BlockSize {
+			block.Encrypt(encrypted[i:i+aes.BlockSize], plain[i:i+aes.BlockSize])
+		}
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(encrypted)))
+		data = append(data, encrypted...)
+	}
+	return data
+}
+
+func writeFixture(t *testing.T, p string, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseMySQLOptionValue(t *testing.T) {
+	tests := []struct {
+		v    string
+		want string
+	}{
+		{v: "plain", want: "plain"},
+		{v: "value # comment", want: "value"},
+		{v: "value\t# comment", want: "value"},
+		{v: "pass#word", want: "pass#word"},
+		{v: `"quoted # not a comment"`, want: "quoted # not a comment"},
+		{v: `'single' # comment`, want: "single"},
+		{v: `a\sb\tc\\d`, want: "a b\tc\\d"},
+		{v: `"line\nbreak"`, want: "line\nbreak"},
+		{v: `C:\path`, want: `C:\path`},
+		{v: `trailing\`, want: `trailing\`},
+		{v: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := parseMySQLOptionValue(tt.v); got != tt.want {
+			t.Errorf("parseMySQLOptionValue(%q) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestReadMySQLOptionFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFixture(t, filepath.Join(dir, "my.cnf"), `# comment
+; another comment
+[mysqld]
+port = 3307
+
+[client]
+host = main.example.com
+default_character_set = utf8mb4
+skip-ssl
+
+[MySQL]
+database = "app # db"
+
+[client_prod]
+host = prod.example.com
+
+!include extra/password.cnf
+!includedir `+filepath.Join(dir, "conf.d")+`
+`)
+	writeFixture(t, filepath.Join(dir, "extra", "password.cnf"), "[client]\npassword = s3cr\\set\n")
+	writeFixture(t, filepath.Join(dir, "conf.d", "20-user.cnf"), "[client]\nuser = second\n")
+	writeFixture(t, filepath.Join(dir, "conf.d", "10-user.cnf"), "[client]\nuser = first\nport = 3308\n")
+	writeFixture(t, filepath.Join(dir, "conf.d", "ignored.txt"), "[client]\nuser = ignored\n")
+
+	tests := []struct {
+		name   string
+		suffix string
+		want   map[string]string
+	}{
+		{
+			name: "default groups",
+			want: map[string]string{
+				"host": "main.example.com", "default-character-set": "utf8mb4", "skip-ssl": "",
+				"database": "app # db", "password": "s3cr et", "user": "second", "port": "3308",
+			},
+		},
+		{
+			name:   "group suffix",
+			suffix: "_prod",
+			want: map[string]string{
+				"host": "prod.example.com", "default-character-set": "utf8mb4", "skip-ssl": "",
+				"database": "app # db", "password": "s3cr et", "user": "second", "port": "3308",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[string]string{}
+			if err := readMySQLOptionFile(filepath.Join(dir, "my.cnf"), mysqlOptionGroups(tt.suffix), got); err != nil {
+				t.Fatalf("readMySQLOptionFile() error = %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("readMySQLOptionFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		got := map[string]string{}
+		if err := readMySQLOptionFile(filepath.Join(dir, "missing.cnf"), mysqlOptionGroups(""), got); err != nil {
+			t.Fatalf("readMySQLOptionFile() error = %v", err)
+		}
+		if len(got) != 0 {
+			t.Errorf("readMySQLOptionFile() = %v, want no options", got)
+		}
+	})
+}
+
+func TestDecryptMySQLLoginFile(t *testing.T) {
+	lines := []string{"[client]", "user = \"admin\"", "password = \"exactly 16 bytes\"", "[prod]", "host = \"prod.example.com\""}
+	data := encryptMySQLLoginFile(t, lines)
+
+	got, err := decryptMySQLLoginFile(data)
+	if err != nil {
+		t.Fatalf("decryptMySQLLoginFile() error = %v", err)
+	}
+	if want := strings.Join(lines, "\n") + "\n"; string(got) != want {
+		t.Errorf("decryptMySQLLoginFile() = %q, want %q", got, want)
+	}
+
+	errorTests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "too short", data: data[:10]},
+		{name: "truncated length", data: data[:26]},
+		{name: "truncated line", data: data[:len(data)-1]},
+		{name: "not a block", data: append(binary.LittleEndian.AppendUint32(bytes.Clone(data[:24]), 15), make([]byte, 15)...)},
+		{name: "invalid padding", data: append(binary.LittleEndian.AppendUint32(bytes.Clone(data[:24]), 16), make([]byte, 16)...)},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decryptMySQLLoginFile(tt.data); err == nil {
+				t.Errorf("decryptMySQLLoginFile() returned no error")
+			}
+		})
+	}
+}
+
+func TestFetchOptionFilesLoginPath(t *testing.T) {
+	dir := t.TempDir()
+	writeFixture(t, filepath.Join(dir, "my.cnf"), "[client]\nhost = file.example.com\nuser = file\ndatabase = app\n")
+	loginFile := filepath.Join(dir, ".mylogin.cnf")
+	writeFixture(t, loginFile, string(encryptMySQLLoginFile(t, []string{
+		"[client]", "user = \"login\"",
+		"[prod]", "host = \"prod.example.com\"", "password = \"p#ss word\"",
+	})))
+	t.Setenv("MYSQL_TEST_LOGIN_FILE", loginFile)
+
+	tests := []struct {
+		name      string
+		loginPath string
+		want      ConnectInfo
+	}{
+		{name: "client", want: ConnectInfo{Server: "file.example.com", User: "login", DbName: "app"}},
+		{name: "login path", loginPath: "prod", want: ConnectInfo{Server: "prod.example.com", User: "login", Password: "p#ss word", DbName: "app"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &MysqlCommander{defaultsFile: filepath.Join(dir, "my.cnf"), loginPath: tt.loginPath}
+			if err := m.fetchOptionFiles(); err != nil {
+				t.Fatalf("fetchOptionFiles() error = %v", err)
+			}
+			if m.connectInfo != tt.want {
+				t.Errorf("fetchOptionFiles() = %+v, want %+v", m.connectInfo, tt.want)
+			}
+		})
+	}
+
+	t.Run("corrupted", func(t *testing.T) {
+		writeFixture(t, loginFile, "short")
+		m := &MysqlCommander{defaultsFile: filepath.Join(dir, "my.cnf")}
+		if err := m.fetchOptionFiles(); err == nil {
+			t.Errorf("fetchOptionFiles() returned no error")
+		}
+	})
+}
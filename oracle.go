@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+type OracleCommander struct {
+	originalArgs []string
+
+	escapedArgs []string
+	connectInfo ConnectInfo
+	query       string
+	readOnly    bool
+	help        bool
+}
+
+func NewOracleCommander(args []string) (*OracleCommander, error) {
+	c := &OracleCommander{}
+	c.originalArgs = args
+	c.escapedArgs = make([]string, 0)
+	c.connectInfo = ConnectInfo{Port: "1521"}
+	if err := c.parseArgs(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (m *OracleCommander) parseArgs(args []string) error {
+	logonParsed := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-H" || arg == "-h" || arg == "-help" || arg == "--help":
+			m.help = true
+			break
+		case strings.HasPrefix(arg, "@"):
+			// The script is shipped through the ConfigMap, and the arguments of the script are not supported
+			data, err := os.ReadFile(strings.TrimPrefix(arg, "@"))
+			if err != nil {
+				return err
+			}
+			m.query = string(data)
+		case arg == "-M" || arg == "-MARKUP" || arg == "-R" || arg == "-RESTRICT" || arg == "-C" || arg == "-COMPATIBILITY":
+			i++
+			m.escapedArgs = append(m.escapedArgs, arg, fmt.Sprintf("\"%s\"", args[i]))
+		case strings.HasPrefix(arg, "-") || strings.EqualFold(arg, "/nolog"):
+			m.escapedArgs = append(m.escapedArgs, arg)
+		case !logonParsed:
+			logonParsed = true
+			m.parseLogon(arg)
+		default:
+			m.escapedArgs = append(m.escapedArgs, fmt.Sprintf("\"%s\"", arg))
+		}
+	}
+	return nil
+}
+
+// parseLogon parses user[/password][@connect_identifier], where the connect identifier is
+// an EZConnect string [//]host[:port][/service_name].
+func (m *OracleCommander) parseLogon(logon string) {
+	userPass, connect, found := logon, "", false
+	if i := strings.LastIndex(logon, "@"); i >= 0 {
+		userPass, connect, found = logon[:i], logon[i+1:], true
+	}
+
+	user, password, _ := strings.Cut(userPass, "/")
+	m.connectInfo.User = user
+	m.connectInfo.Password = strings.Trim(password, "\"")
+	if !found {
+		return
+	}
+
+	hostPort, service, _ := strings.Cut(strings.TrimPrefix(connect, "//"), "/")
+	host, port, hasPort := strings.Cut(hostPort, ":")
+	m.connectInfo.Server = host
+	if hasPort {
+		m.connectInfo.Port = port
+	}
+	m.connectInfo.DbName = service
+}
+
+func (m *OracleCommander) IsInteractive() bool {
+	return m.query == "" && !m.help
+}
+
+func (m *OracleCommander) ConnectInfo() ConnectInfo {
+	return m.connectInfo
+}
+
+func (m *OracleCommander) SetConnectInfo(connectInfo ConnectInfo) {
+	m.connectInfo = connectInfo
+}
+
+func (m *OracleCommander) Query() string {
+	return m.query
+}
+
+func (m *OracleCommander) HelpCommand() string {
+	return "sqlplus -H"
+}
+
+// logon returns the logon argument of sqlplus built from the credentials in the Secret.
+func (m *OracleCommander) logon() string {
+	connect := fmt.Sprintf("//%s:%s", m.connectInfo.Server, m.connectInfo.Port)
+	if m.connectInfo.DbName != "" {
+		connect = fmt.Sprintf("%s/%s", connect, m.connectInfo.DbName)
+	}
+	return fmt.Sprintf("\"$SECRET_DB_USER/\\\"$SECRET_DB_PASSWORD\\\"@%s\"", connect)
+}
+
+// ConfigureReadOnly ships login.sql, which sqlplus runs from ORACLE_PATH after connecting.
+// SET TRANSACTION READ ONLY lasts until the next COMMIT or ROLLBACK.
+func (m *OracleCommander) ConfigureReadOnly() error {
+	m.readOnly = true
+	return nil
+}
+
+func (m *OracleCommander) Files() map[string]string {
+	files := map[string]string{}
+	if m.readOnly {
+		files["login.sql"] = "SET TRANSACTION READ ONLY;\n"
+	}
+	return files
+}
+
+func (m *OracleCommander) envPrefix() string {
+	if m.readOnly {
+		return "ORACLE_PATH=/sql SQLPATH=/sql "
+	}
+	return ""
+}
+
+func (m *OracleCommander) Command() string {
+	if m.help {
+		return m.HelpCommand()
+	}
+
+	connectionArgs := []string{
+		"sqlplus",
+		"-S",
+		"-L",
+	}
+	args := slices.Concat(connectionArgs, m.escapedArgs, []string{m.logon(), "@/sql/query.sql"})
+	// sqlplus exits when the script finishes without EXIT because stdin is closed
+	return fmt.Sprintf("%s%s < /dev/null", m.envPrefix(), strings.Join(args, " "))
+}
+
+func (m *OracleCommander) InteractiveCommand() string {
+	connectionArgs := []string{
+		"sqlplus",
+		"-L",
+	}
+	return m.envPrefix() + strings.Join(slices.Concat(connectionArgs, m.escapedArgs, []string{m.logon()}), " ")
+}
+
+func (m *OracleCommander) ContainerImage() string {
+	return "ghcr.io/oracle/oraclelinux8-instantclient:21"
+}
+
+func (m *OracleCommander) SecretEnvKV() map[string]string {
+	return map[string]string{
+		"SECRET_DB_USER":     "username",
+		"SECRET_DB_PASSWORD": "password",
+	}
+}
+
+func (m *OracleCommander) CommandType() CommandType {
+	return Oracle
+}
+
+func (m *OracleCommander) ParseResults(result string) []string {
+	return []string{}
+}
+
+func OracleCommands() *cli.Command {
+	return &cli.Command{
+		Name:            "sqlplus",
+		Usage:           "execute sqlplus commands",
+		ArgsUsage:       "<sqlplus options> user[/password]@host[:port]/service [@script.sql]",
+		SkipFlagParsing: true,
+		Action:          executeOracleAction,
+	}
+}
+
+func executeOracleAction(c *cli.Context) error {
+	config, err := NewConfig(c)
+	if err != nil {
+		return err
+	}
+	podName, err := CreatePodName("podsql")
+	if err != nil {
+		return err
+	}
+	args := c.Args().Slice()
+	if len(args) == 0 {
+		args = []string{"-H"}
+	}
+	dbCommander, err := NewOracleCommander(args)
+	if err != nil {
+		return err
+	}
+	return runDBCommander(config, podName, dbCommander)
+}
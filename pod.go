@@ -64,14 +64,28 @@ func createBasicAuthSecretSpec(secretName, namespace string, connectInfo Connect
 	}
 }
 
-func newClientset() (*kubernetes.Clientset, *rest.Config, error) {
-	kubeconfig := os.Getenv("KUBECONFIG")
-	if kubeconfig == "" {
-		kubeconfig = filepath.Join(homedir.HomeDir(), ".kube", "config")
+// PodCustomizer is implemented by DBCommanders that need additional settings in the pod spec.
+type PodCustomizer interface {
+	CustomizePod(pod *corev1.Pod)
+}
+
+func customizePod(pod *corev1.Pod, dbCommander DBCommander) *corev1.Pod {
+	if c, ok := dbCommander.(PodCustomizer); ok {
+		c.CustomizePod(pod)
+	}
+	return pod
+}
+
+func kubeconfigPath() string {
+	if kubeconfig := os.Getenv("KUBECONFIG"); kubeconfig != "" {
+		return kubeconfig
 	}
+	return filepath.Join(homedir.HomeDir(), ".kube", "config")
+}
 
+func newClientset() (*kubernetes.Clientset, *rest.Config, error) {
 	// Setup Kubernetes client
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfigPath())
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to build config from flags: %w", err)
 	}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestPostgresCountRows(t *testing.T) {
+	const result = " id | note\n----+----------\n  1 | (9 rows)\n  2 | UPDATE 7\n(2 rows)\n\nUPDATE 3\nINSERT 0 1\n"
+	tests := []struct {
+		name   string
+		args   []string
+		output string
+		want   int
+		none   bool
+	}{
+		{name: "footer and command tags", args: []string{"-c", "SELECT 1"}, output: result, want: 6},
+		{name: "no rows", args: []string{"-c", "SELECT 1"}, output: " id\n----\n(0 rows)\n", want: 0},
+		{name: "no counts in the output", args: []string{"-c", "CREATE TABLE t (a int)"}, output: "CREATE TABLE\n", none: true},
+		{name: "unaligned", args: []string{"-A", "-c", "SELECT 1"}, output: result, none: true},
+		{name: "combined options", args: []string{"-Xt", "-c", "SELECT 1"}, output: result, none: true},
+		{name: "option value", args: []string{"-v", "x=tAP", "-c", "SELECT 1"}, output: result, want: 6},
+		{name: "csv", args: []string{"--csv", "-c", "SELECT 1"}, output: result, none: true},
+		{name: "pset", args: []string{"-P", "footer=off", "-c", "SELECT 1"}, output: result, none: true},
+		{name: "meta command", args: []string{"-c", "\\x\nSELECT 1"}, output: result, none: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewPostgresCommander(append([]string{"-h", "db", "-U", "app", "-d", "app"}, tt.args...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := m.CountRows(tt.output)
+			if tt.none {
+				if got != nil {
+					t.Errorf("CountRows() = %d, want nil", *got)
+				}
+				return
+			}
+			if got == nil || *got != tt.want {
+				t.Errorf("CountRows() = %v, want %d", got, tt.want)
+			}
+		})
+	}
+}
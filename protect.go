@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// confirmInput and confirmOutput are the terminal used to confirm destructive queries on protected profiles.
+var (
+	confirmInput  io.Reader = os.Stdin
+	confirmOutput io.Writer = os.Stderr
+)
+
+// destructiveKeywords make a statement destructive wherever they appear, e.g. ALTER TABLE ... DROP COLUMN.
+var destructiveKeywords = []string{"DROP", "TRUNCATE", "ALTER"}
+
+// dynamicKeywords run SQL that the check cannot see, e.g. EXECUTE 'TRUNCATE t' or a procedure,
+// so they are confirmed like destructive statements.
+var dynamicKeywords = []string{"EXECUTE", "EXEC", "CALL"}
+
+// includeMetaCommands of psql and sqlcmd run the statements of a file, which the check cannot see.
+var includeMetaCommands = []string{`\i`, `\ir`, `\include`, `\include_relative`, ":r"}
+
+// isSQLDialect reports whether the queries of the command are SQL, which the tokenizer can classify.
+func isSQLDialect(commandType CommandType) bool {
+	switch commandType {
+	case MySQL, PostgreSQL, SQLCmd, Oracle, ClickHouse:
+		return true
+	default:
+		return false
+	}
+}
+
+// destructiveStatements returns the statements of the query that drop, truncate or alter objects,
+// or delete or update rows without a WHERE clause, and the ones running SQL hidden from the check:
+// DO blocks, EXECUTE, CALL, \gexec of psql and the /*! */ comments of MySQL.
+func destructiveStatements(query string, dialect CommandType) []sqlStatement {
+	statements := []sqlStatement{}
+	for _, s := range splitSQLStatements(query, dialect) {
+		if isDestructiveStatement(s, dialect) {
+			statements = append(statements, s)
+		}
+	}
+	return statements
+}
+
+func isDestructiveStatement(s sqlStatement, dialect CommandType) bool {
+	if s.tokens[0].kind == sqlMetaCommand {
+		// \gexec runs each value of the result as a statement
+		command, _, _ := strings.Cut(s.tokens[0].text, " ")
+		return command == `\gexec` || slices.Contains(includeMetaCommands, strings.ToLower(command))
+	}
+	// the client commands that run a file: source and \. of mysql, @, @@ and START of sqlplus
+	switch dialect {
+	case MySQL:
+		if s.keyword(0) == "SOURCE" || len(s.tokens) > 1 && s.tokens[0].text == `\` && s.tokens[1].text == "." {
+			return true
+		}
+	case Oracle:
+		if s.keyword(0) == "START" || strings.HasPrefix(s.tokens[0].text, "@") {
+			return true
+		}
+	}
+	// DO runs an anonymous code block of PostgreSQL
+	if s.keyword(0) == "DO" {
+		return true
+	}
+	for i, t := range s.tokens {
+		// the body of a /*! */ comment runs only on some server versions, so it is confirmed whatever it contains
+		if t.kind == sqlPunct && t.text == "/*!" {
+			return true
+		}
+		if t.kind != sqlWord {
+			continue
+		}
+		if slices.Contains(destructiveKeywords, t.text) || slices.Contains(dynamicKeywords, t.text) {
+			return true
+		}
+		if t.text != "DELETE" && t.text != "UPDATE" {
+			continue
+		}
+		if i+1 < len(s.tokens) && s.tokens[i+1].text == "(" {
+			continue
+		}
+		// ON DELETE CASCADE, SELECT ... FOR UPDATE, ON DUPLICATE KEY UPDATE and WHEN MATCHED THEN UPDATE
+		// do not change all the rows by themselves
+		if i > 0 && s.tokens[i-1].kind == sqlWord && slices.Contains([]string{"ON", "FOR", "KEY", "THEN"}, s.tokens[i-1].text) {
+			continue
+		}
+		if !hasWhereClause(s.tokens[i+1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasWhereClause reports whether WHERE follows at the same parenthesis level,
+// before the parenthesis enclosing the statement is closed, e.g. in WITH d AS (DELETE ...).
+func hasWhereClause(tokens []sqlToken) bool {
+	depth := 0
+	for _, t := range tokens {
+		switch {
+		case t.kind == sqlPunct && t.text == "(":
+			depth++
+		case t.kind == sqlPunct && t.text == ")":
+			depth--
+			if depth < 0 {
+				return false
+			}
+		case t.kind == sqlWord && t.text == "WHERE" && depth == 0:
+			return true
+		}
+	}
+	return false
+}
+
+// confirmDestructiveQuery asks to type the name of the database before running destructive statements on a protected profile.
+// --yes skips the confirmation for automation.
+func confirmDestructiveQuery(conf *Config, dbCommander DBCommander) error {
+	if !conf.Profile.Protected || conf.AssumeYes || !isSQLDialect(dbCommander.CommandType()) {
+		return nil
+	}
+	statements := destructiveStatements(dbCommander.Query(), dbCommander.CommandType())
+	if len(statements) == 0 {
+		return nil
+	}
+
+	connectInfo := dbCommander.ConnectInfo()
+	target := connectInfo.Server
+	if connectInfo.Port != "" {
+		target = fmt.Sprintf("%s:%s", target, connectInfo.Port)
+	}
+	// Some clients connect without a database, then the host has to be typed instead
+	expected := connectInfo.DbName
+	if expected == "" {
+		expected = connectInfo.Server
+	}
+
+	fmt.Fprintf(confirmOutput, "Profile %s is protected. The query contains destructive statements:\n\n", conf.ProfileName)
+	for _, s := range statements {
+		fmt.Fprintf(confirmOutput, "  %s\n", strings.ReplaceAll(s.text, "\n", "\n  "))
+	}
+	if connectInfo.DbName != "" {
+		target = fmt.Sprintf("%s, database %s", target, connectInfo.DbName)
+	}
+	fmt.Fprintf(confirmOutput, "\nTarget: host %s\n", target)
+	fmt.Fprintf(confirmOutput, "Type %q to continue: ", expected)
+
+	answer, err := bufio.NewReader(confirmInput).ReadString('\n')
+	if err != nil && (err != io.EOF || answer == "") {
+		return fmt.Errorf("failed to read the confirmation, use --yes to run without it: %w", err)
+	}
+	if strings.TrimSpace(answer) != expected {
+		return fmt.Errorf("confirmation did not match %q, the query was not run", expected)
+	}
+	return nil
+}
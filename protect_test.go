@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDestructiveStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect CommandType
+		query   string
+		want    []string
+	}{
+		{name: "select", dialect: MySQL, query: "SELECT * FROM t", want: []string{}},
+		{name: "drop", dialect: MySQL, query: "SELECT 1; DROP TABLE t", want: []string{"DROP TABLE t"}},
+		{name: "alter", dialect: PostgreSQL, query: "ALTER TABLE t ADD COLUMN a int", want: []string{"ALTER TABLE t ADD COLUMN a int"}},
+		{name: "delete with where", dialect: MySQL, query: "DELETE FROM t WHERE id = 1", want: []string{}},
+		{name: "delete without where", dialect: MySQL, query: "DELETE FROM t", want: []string{"DELETE FROM t"}},
+		{name: "update without where", dialect: SQLCmd, query: "UPDATE t SET a = 1\nGO", want: []string{"UPDATE t SET a = 1"}},
+		{name: "for update", dialect: PostgreSQL, query: "SELECT * FROM t FOR UPDATE", want: []string{}},
+		{name: "on delete cascade", dialect: PostgreSQL, query: "CREATE TABLE c (p int REFERENCES t ON DELETE CASCADE)", want: []string{}},
+		{name: "on duplicate key update", dialect: MySQL, query: "INSERT INTO t VALUES (1) ON DUPLICATE KEY UPDATE a = 1", want: []string{}},
+		{name: "drop in a string", dialect: MySQL, query: "SELECT 'DROP TABLE t'", want: []string{}},
+
+		{name: "do block", dialect: PostgreSQL, query: "DO $$ BEGIN DROP TABLE t; END $$", want: []string{"DO $$ BEGIN DROP TABLE t; END $$"}},
+		{name: "execute", dialect: PostgreSQL, query: "EXECUTE 'TRUNCATE t'", want: []string{"EXECUTE 'TRUNCATE t'"}},
+		{name: "exec", dialect: SQLCmd, query: "EXEC('TRUNCATE TABLE t')", want: []string{"EXEC('TRUNCATE TABLE t')"}},
+		{name: "call", dialect: MySQL, query: "CALL purge()", want: []string{"CALL purge()"}},
+		{name: "gexec", dialect: PostgreSQL, query: "SELECT 'DROP TABLE t' \\gexec", want: []string{`\gexec`}},
+		{name: "mysql executable comment", dialect: MySQL, query: "/*! DROP TABLE t */", want: []string{"/*! DROP TABLE t"}},
+
+		{name: "psql include", dialect: PostgreSQL, query: "\\i /data/fix.sql", want: []string{`\i /data/fix.sql`}},
+		{name: "psql relative include", dialect: PostgreSQL, query: "SELECT 1;\n\\ir fix.sql", want: []string{`\ir fix.sql`}},
+		{name: "psql long include", dialect: PostgreSQL, query: "\\include_relative fix.sql", want: []string{`\include_relative fix.sql`}},
+		{name: "psql other command", dialect: PostgreSQL, query: "\\x\nSELECT 1", want: []string{}},
+		{name: "sqlcmd include", dialect: SQLCmd, query: ":r /data/fix.sql\nGO", want: []string{":r /data/fix.sql"}},
+		{name: "sqlcmd upper-case include", dialect: SQLCmd, query: ":R fix.sql", want: []string{":R fix.sql"}},
+		{name: "mysql source", dialect: MySQL, query: "source /data/fix.sql", want: []string{"source /data/fix.sql"}},
+		{name: "mysql backslash source", dialect: MySQL, query: "\\. /data/fix.sql", want: []string{`\. /data/fix.sql`}},
+		{name: "sqlplus at", dialect: Oracle, query: "@/data/fix.sql", want: []string{"@/data/fix.sql"}},
+		{name: "sqlplus double at", dialect: Oracle, query: "@@fix.sql", want: []string{"@@fix.sql"}},
+		{name: "sqlplus start", dialect: Oracle, query: "START fix.sql", want: []string{"START fix.sql"}},
+		{name: "start transaction", dialect: MySQL, query: "START TRANSACTION", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, s := range destructiveStatements(tt.query, tt.dialect) {
+				got = append(got, s.text)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("destructiveStatements(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfirmDestructiveQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    Config
+		query   string
+		answer  string
+		wantErr bool
+		asked   bool
+	}{
+		{name: "not protected", conf: Config{}, query: "DROP TABLE t", asked: false},
+		{name: "assume yes", conf: Config{Profile: Profile{Protected: true}, AssumeYes: true}, query: "DROP TABLE t", asked: false},
+		{name: "not destructive", conf: Config{Profile: Profile{Protected: true}}, query: "SELECT 1", asked: false},
+		{name: "confirmed", conf: Config{Profile: Profile{Protected: true}}, query: "DROP TABLE t", answer: "app\n", asked: true},
+		{name: "wrong name", conf: Config{Profile: Profile{Protected: true}}, query: "DROP TABLE t", answer: "prod\n", asked: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			origInput, origOutput := confirmInput, confirmOutput
+			confirmInput, confirmOutput = strings.NewReader(tt.answer), &out
+			t.Cleanup(func() { confirmInput, confirmOutput = origInput, origOutput })
+
+			m, err := NewMysqlCommander([]string{"--no-defaults", "--flavor=mysql", "-h", "db.example.com", "-D", "app", "-e", tt.query})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = confirmDestructiveQuery(&tt.conf, m)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("confirmDestructiveQuery() error = %v, want error %v", err, tt.wantErr)
+			}
+			if asked := out.Len() > 0; asked != tt.asked {
+				t.Errorf("asked = %v, want %v: %s", asked, tt.asked, out.String())
+			}
+		})
+	}
+}
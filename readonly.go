@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ReadOnlyConfigurer is implemented by DBCommanders that can start the session in read-only mode.
+type ReadOnlyConfigurer interface {
+	ConfigureReadOnly() error
+}
+
+func configureReadOnly(conf *Config, dbCommander DBCommander) error {
+	if !conf.ReadOnly {
+		return nil
+	}
+	c, ok := dbCommander.(ReadOnlyConfigurer)
+	if !ok {
+		return fmt.Errorf("%s does not support --read-only", dbCommander.CommandType().CommandName())
+	}
+	return c.ConfigureReadOnly()
+}
+
+// readOnlyKeywords are the statements allowed in read-only mode, by their first keyword.
+var readOnlyKeywords = []string{
+	"SELECT", "WITH", "VALUES", "TABLE", "SHOW", "DESCRIBE", "DESC", "EXPLAIN", "USE", "HELP",
+	"BEGIN", "START", "COMMIT", "ROLLBACK", "END", "SET", "PRINT", "DECLARE",
+}
+
+// writeKeywords make a statement a write wherever they appear, e.g. WITH ... DELETE or EXPLAIN ANALYZE UPDATE.
+// They are ignored when followed by a parenthesis, because REPLACE() and others are also function names.
+var writeKeywords = []string{
+	"INSERT", "UPDATE", "DELETE", "MERGE", "UPSERT", "REPLACE", "INTO",
+	"CREATE", "ALTER", "DROP", "TRUNCATE", "GRANT", "REVOKE", "CALL", "EXEC", "EXECUTE",
+}
+
+// sessionKeywords in a SET statement could switch the session back to read-write. They are also matched
+// as MySQL system variables with @@, e.g. SET @@session.tx_read_only = 0.
+var sessionKeywords = []string{
+	"TRANSACTION", "SESSION", "GLOBAL", "PERSIST", "ROLE", "AUTHORIZATION",
+	"DEFAULT_TRANSACTION_READ_ONLY", "TRANSACTION_READ_ONLY", "TX_READ_ONLY", "READONLY",
+}
+
+// sessionFunctions change the settings of the session from a query, e.g. SELECT set_config('default_transaction_read_only', 'off', false).
+var sessionFunctions = []string{"SET_CONFIG"}
+
+// blockKeywords start procedural blocks in Oracle and SQL Server, whose bodies are not classified.
+// COMMIT and ROLLBACK would also end the SET TRANSACTION READ ONLY of login.sql in Oracle.
+var blockKeywords = map[CommandType][]string{
+	Oracle: {"BEGIN", "DECLARE", "COMMIT", "ROLLBACK", "END"},
+	SQLCmd: {"BEGIN", "DECLARE"},
+}
+
+// readOnlyMetaCommands are the client commands allowed in read-only mode, e.g. \d of psql and :setvar of sqlcmd.
+var readOnlyMetaCommands = []string{
+	`\d`, `\l`, `\x`, `\a`, `\t`, `\timing`, `\pset`, `\echo`, `\conninfo`, `\encoding`, `\q`, `\?`, `\h`,
+	":setvar", ":on", ":listvar", ":error", ":out", ":quit", ":exit",
+}
+
+// checkReadOnlyQuery classifies the statements of the query locally and rejects the ones that may write.
+func checkReadOnlyQuery(query string, dialect CommandType) error {
+	for _, s := range splitSQLStatements(query, dialect) {
+		if isReadOnlyStatement(s, dialect) {
+			continue
+		}
+		return fmt.Errorf("statement is not allowed in read-only mode: %s", s.summary())
+	}
+	return nil
+}
+
+func isReadOnlyStatement(s sqlStatement, dialect CommandType) bool {
+	if s.tokens[0].kind == sqlMetaCommand {
+		// A command after a query, such as \gexec or \g, runs or redirects the query
+		if s.inline {
+			return false
+		}
+		command, _, _ := strings.Cut(s.tokens[0].text, " ")
+		return slices.ContainsFunc(readOnlyMetaCommands, func(allowed string) bool {
+			// \d matches \dt and \d+ as well
+			return strings.EqualFold(command, allowed) || (allowed == `\d` && strings.HasPrefix(command, `\d`))
+		})
+	}
+
+	first := s.keyword(0)
+	if !slices.Contains(readOnlyKeywords, first) || slices.Contains(blockKeywords[dialect], first) {
+		return false
+	}
+	// START REPLICA and others change the server
+	if first == "START" && s.keyword(1) != "TRANSACTION" {
+		return false
+	}
+	for i, t := range s.tokens {
+		// "set_config" is a valid function name as well
+		if (t.kind == sqlWord || t.kind == sqlIdentifier) && slices.Contains(sessionFunctions, strings.ToUpper(t.text)) {
+			return false
+		}
+		if t.kind != sqlWord {
+			continue
+		}
+		// BEGIN READ WRITE, START TRANSACTION READ WRITE
+		if t.text == "WRITE" && i > 0 && s.tokens[i-1].text == "READ" {
+			return false
+		}
+		if first == "SET" && slices.Contains(sessionKeywords, strings.TrimPrefix(t.text, "@@")) {
+			return false
+		}
+		if slices.Contains(writeKeywords, t.text) {
+			if i+1 < len(s.tokens) && s.tokens[i+1].text == "(" {
+				continue
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// summary returns the beginning of the statement for error messages.
+func (s sqlStatement) summary() string {
+	words := []string{}
+	for _, t := range s.tokens {
+		if len(words) == 5 {
+			words = append(words, "...")
+			break
+		}
+		words = append(words, t.text)
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,74 @@
+package main
+
+import "testing"
+
+func TestCheckReadOnlyQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect CommandType
+		query   string
+		allowed bool
+	}{
+		{name: "select", dialect: MySQL, query: "SELECT * FROM users WHERE id = 1", allowed: true},
+		{name: "cte", dialect: PostgreSQL, query: "WITH t AS (SELECT 1) SELECT * FROM t", allowed: true},
+		{name: "show and explain", dialect: MySQL, query: "SHOW TABLES; EXPLAIN SELECT 1", allowed: true},
+		{name: "replace function", dialect: MySQL, query: "SELECT REPLACE(name, 'a', 'b') FROM users", allowed: true},
+		{name: "keyword in a string", dialect: MySQL, query: "SELECT 'DROP TABLE t'", allowed: true},
+		{name: "keyword in a comment", dialect: PostgreSQL, query: "SELECT 1 /* DELETE /* nested */ FROM t */", allowed: true},
+		{name: "keyword in an identifier", dialect: SQLCmd, query: "SELECT [delete] FROM t", allowed: true},
+		{name: "keyword in a dollar quote", dialect: PostgreSQL, query: "SELECT $x$DROP TABLE t$x$", allowed: true},
+		{name: "transaction", dialect: PostgreSQL, query: "BEGIN; SELECT 1; COMMIT", allowed: true},
+		{name: "set a variable", dialect: MySQL, query: "SET @id = 1; SELECT @id", allowed: true},
+		{name: "psql meta command", dialect: PostgreSQL, query: "\\dt\nSELECT 1", allowed: true},
+		{name: "sqlcmd variable", dialect: SQLCmd, query: ":setvar id 1\nSELECT $(id)\nGO", allowed: true},
+
+		{name: "insert", dialect: MySQL, query: "INSERT INTO t VALUES (1)", allowed: false},
+		{name: "second statement", dialect: MySQL, query: "SELECT 1; DROP TABLE t", allowed: false},
+		{name: "cte with delete", dialect: PostgreSQL, query: "WITH d AS (DELETE FROM t RETURNING *) SELECT * FROM d", allowed: false},
+		{name: "explain analyze", dialect: PostgreSQL, query: "EXPLAIN ANALYZE UPDATE t SET a = 1", allowed: false},
+		{name: "select into", dialect: PostgreSQL, query: "SELECT * INTO copy FROM t", allowed: false},
+		{name: "read write transaction", dialect: PostgreSQL, query: "BEGIN READ WRITE", allowed: false},
+		{name: "set session", dialect: PostgreSQL, query: "SET SESSION CHARACTERISTICS AS TRANSACTION READ WRITE", allowed: false},
+		{name: "start replica", dialect: MySQL, query: "START REPLICA", allowed: false},
+		{name: "unknown statement", dialect: MySQL, query: "LOCK TABLES t WRITE", allowed: false},
+
+		{name: "mysql executable comment", dialect: MySQL, query: "/*! DROP TABLE t */", allowed: false},
+		{name: "mysql versioned executable comment", dialect: MySQL, query: "SELECT 1 /*!50700 ; DROP TABLE t */", allowed: false},
+		{name: "mariadb executable comment", dialect: MySQL, query: "SELECT 1; /*M! DELETE FROM t */", allowed: false},
+		{name: "mysql executable comment in a select", dialect: MySQL, query: "SELECT /*!40001 SQL_NO_CACHE */ * FROM t", allowed: true},
+		{name: "mysql optimizer hint", dialect: MySQL, query: "SELECT /*+ MAX_EXECUTION_TIME(1000) */ * FROM t", allowed: true},
+
+		{name: "oracle block", dialect: Oracle, query: "BEGIN pkg.purge; END;", allowed: false},
+		{name: "oracle commit", dialect: Oracle, query: "COMMIT; BEGIN pkg.purge; END;", allowed: false},
+		{name: "oracle declare", dialect: Oracle, query: "DECLARE n NUMBER; BEGIN n := 1; END;", allowed: false},
+		{name: "oracle select", dialect: Oracle, query: "SELECT * FROM dual", allowed: true},
+		{name: "sqlserver block", dialect: SQLCmd, query: "BEGIN TRAN\nGO", allowed: false},
+		{name: "sqlserver declare", dialect: SQLCmd, query: "DECLARE @sql NVARCHAR(100) = 'DROP TABLE t'", allowed: false},
+
+		{name: "tx_read_only", dialect: MySQL, query: "SET tx_read_only=0", allowed: false},
+		{name: "system variable tx_read_only", dialect: MySQL, query: "SET @@tx_read_only = 0", allowed: false},
+		{name: "session tx_read_only", dialect: MySQL, query: "SET @@session.tx_read_only = 0", allowed: false},
+		{name: "local tx_read_only", dialect: MySQL, query: "SET LOCAL tx_read_only = 0", allowed: false},
+		{name: "system variable transaction_read_only", dialect: MySQL, query: "SET @@transaction_read_only = OFF", allowed: false},
+		{name: "user variable", dialect: MySQL, query: "SET @tx_read_only = 0; SELECT @tx_read_only", allowed: true},
+
+		{name: "set_config", dialect: PostgreSQL, query: "SELECT set_config('default_transaction_read_only', 'off', false)", allowed: false},
+		{name: "qualified set_config", dialect: PostgreSQL, query: "SELECT pg_catalog.set_config('transaction_read_only', 'off', true)", allowed: false},
+		{name: "quoted set_config", dialect: PostgreSQL, query: `SELECT "set_config"('default_transaction_read_only', 'off', false)`, allowed: false},
+		{name: "gexec after a query", dialect: PostgreSQL, query: "SELECT 'DROP TABLE t' \\gexec", allowed: false},
+		{name: "allowed command after a query", dialect: PostgreSQL, query: "SELECT 1 \\x", allowed: false},
+		{name: "gexec on its own line", dialect: PostgreSQL, query: "SELECT 'DROP TABLE t'\n\\gexec", allowed: false},
+		{name: "backslash in an E string", dialect: PostgreSQL, query: "SELECT E'a\\'b'", allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkReadOnlyQuery(tt.query, tt.dialect)
+			if tt.allowed && err != nil {
+				t.Errorf("checkReadOnlyQuery(%q) = %v, want allowed", tt.query, err)
+			}
+			if !tt.allowed && err == nil {
+				t.Errorf("checkReadOnlyQuery(%q) allowed the query", tt.query)
+			}
+		})
+	}
+}
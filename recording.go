@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// RecordingConfig enables the recording of interactive sessions in the asciicast v2 format.
+// A session is recorded when Dir is set or --record is given, and the file is uploaded after the session if Upload is set.
+// The recording of a profile replaces the top-level one, e.g. to always record production sessions.
+type RecordingConfig struct {
+	// Dir is the local directory of the recordings, named <pod>.cast
+	Dir string `yaml:"dir"`
+	// Input records the keystrokes as well, which includes passwords typed into the client
+	Input  bool                   `yaml:"input"`
+	Upload *RecordingUploadConfig `yaml:"upload"`
+}
+
+// RecordingUploadConfig is where the recordings are uploaded: an URL to PUT them to, or an S3 bucket.
+type RecordingUploadConfig struct {
+	// URL may contain {name}, which is replaced with the file name of the recording.
+	// The headers are sent with it, e.g. for authorization.
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	S3      *S3UploadConfig   `yaml:"s3"`
+}
+
+type S3UploadConfig struct {
+	Bucket string `yaml:"bucket"`
+	Prefix string `yaml:"prefix"`
+	Region string `yaml:"region"`
+}
+
+// asciicastHeader is the first line of an asciicast v2 file.
+type asciicastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// sessionRecorder writes the events of a session as the lines of an asciicast v2 file: [seconds, "o", "data"].
+type sessionRecorder struct {
+	path   string
+	upload *RecordingUploadConfig
+	input  bool
+
+	mu    sync.Mutex
+	file  *os.File
+	w     *bufio.Writer
+	start time.Time
+	// pending holds the incomplete UTF-8 sequences at the end of the last chunk of each stream
+	pending map[string][]byte
+}
+
+// startRecording creates the recording of the session, or returns nil if recording is disabled.
+func startRecording(conf *Config, podName string, size *remotecommand.TerminalSize) (*sessionRecorder, error) {
+	p := conf.RecordPath
+	if p == "" && conf.Recording.Dir != "" {
+		p = filepath.Join(expandHome(conf.Recording.Dir), podName+".cast")
+	}
+	if p == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	r := &sessionRecorder{
+		path:    p,
+		upload:  conf.Recording.Upload,
+		input:   conf.Recording.Input,
+		file:    f,
+		w:       bufio.NewWriter(f),
+		start:   time.Now(),
+		pending: map[string][]byte{},
+	}
+	header := asciicastHeader{Version: 2, Width: 80, Height: 24, Timestamp: r.start.Unix(), Title: podName,
+		Env: map[string]string{"TERM": os.Getenv("TERM"), "SHELL": os.Getenv("SHELL")}}
+	if size != nil {
+		header.Width, header.Height = int(size.Width), int(size.Height)
+	}
+	data, err := json.Marshal(header)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.w.Write(append(data, '\n'))
+	return r, nil
+}
+
+func expandHome(p string) string {
+	if !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(homeDir, p[2:])
+}
+
+func (r *sessionRecorder) event(kind string, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data = append(r.pending[kind], data...)
+	data, r.pending[kind] = splitIncompleteUTF8(data)
+	if len(data) == 0 {
+		return
+	}
+	r.writeEvent(kind, string(data))
+}
+
+func (r *sessionRecorder) writeEvent(kind, data string) {
+	line, err := json.Marshal([]any{time.Since(r.start).Seconds(), kind, data})
+	if err != nil {
+		return
+	}
+	r.w.Write(append(line, '\n'))
+}
+
+// splitIncompleteUTF8 keeps a multibyte character split between two reads for the next event,
+// because the events are JSON strings.
+func splitIncompleteUTF8(b []byte) ([]byte, []byte) {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if !utf8.RuneStart(b[i]) {
+			continue
+		}
+		if utf8.FullRune(b[i:]) {
+			return b, nil
+		}
+		return b[:i], append([]byte{}, b[i:]...)
+	}
+	return b, nil
+}
+
+// Output returns the writer of the terminal that also records what is written.
+func (r *sessionRecorder) Output(w io.Writer) io.Writer {
+	if r == nil {
+		return w
+	}
+	return &recordingWriter{w: w, r: r}
+}
+
+// Input returns the reader of the terminal that also records the keystrokes if Input is enabled.
+func (r *sessionRecorder) Input(rd io.Reader) io.Reader {
+	if r == nil || !r.input {
+		return rd
+	}
+	return &recordingReader{rd: rd, r: r}
+}
+
+// SizeQueue records the resizes of the terminal as "r" events.
+func (r *sessionRecorder) SizeQueue(q remotecommand.TerminalSizeQueue) remotecommand.TerminalSizeQueue {
+	if r == nil || q == nil {
+		return q
+	}
+	return &recordingSizeQueue{q: q, r: r}
+}
+
+type recordingWriter struct {
+	w io.Writer
+	r *sessionRecorder
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.r.event("o", p)
+	return w.w.Write(p)
+}
+
+type recordingReader struct {
+	rd io.Reader
+	r  *sessionRecorder
+}
+
+func (rd *recordingReader) Read(p []byte) (int, error) {
+	n, err := rd.rd.Read(p)
+	if n > 0 {
+		rd.r.event("i", p[:n])
+	}
+	return n, err
+}
+
+type recordingSizeQueue struct {
+	q remotecommand.TerminalSizeQueue
+	r *sessionRecorder
+}
+
+func (s *recordingSizeQueue) Next() *remotecommand.TerminalSize {
+	size := s.q.Next()
+	if size != nil {
+		s.r.mu.Lock()
+		s.r.writeEvent("r", fmt.Sprintf("%dx%d", size.Width, size.Height))
+		s.r.mu.Unlock()
+	}
+	return size
+}
+
+// Close finishes the recording and uploads it. The local file is kept even if the upload fails.
+func (r *sessionRecorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	for kind, rest := range r.pending {
+		if len(rest) > 0 {
+			r.writeEvent(kind, string(rest))
+		}
+	}
+	err := errors.Join(r.w.Flush(), r.file.Close())
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to write recording %s: %w", r.path, err)
+	}
+	if r.upload != nil {
+		if err := uploadRecording(*r.upload, r.path); err != nil {
+			return fmt.Errorf("failed to upload recording %s: %w", r.path, err)
+		}
+	}
+	return nil
+}
+
+func uploadRecording(c RecordingUploadConfig, p string) error {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	name := filepath.Base(p)
+	var req *http.Request
+	switch {
+	case c.S3 != nil:
+		req, err = newS3PutRequest(ctx, *c.S3, name, data)
+	case c.URL != "":
+		req, err = http.NewRequestWithContext(ctx, http.MethodPut, strings.ReplaceAll(c.URL, "{name}", name), bytes.NewReader(data))
+		if err == nil {
+			for k, v := range c.Headers {
+				req.Header.Set(k, os.ExpandEnv(v))
+			}
+		}
+	default:
+		return fmt.Errorf("url or s3 is required to upload recordings")
+	}
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func newS3PutRequest(ctx context.Context, c S3UploadConfig, name string, data []byte) (*http.Request, error) {
+	region := c.Region
+	if region == "" {
+		region = AWSRegion()
+	}
+	if c.Bucket == "" || region == "" {
+		return nil, fmt.Errorf("bucket and region are required for S3")
+	}
+	creds, err := LoadAWSCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS credentials: %w", err)
+	}
+	key := strings.TrimLeft(strings.TrimSuffix(c.Prefix, "/")+"/"+name, "/")
+	u := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", c.Bucket, region, key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-asciicast")
+	req.Header.Set("X-Amz-Content-Sha256", sha256Hex(data))
+	signSigV4(req, data, creds, region, "s3", time.Now())
+	return req, nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+type RedisCommander struct {
+	originalArgs []string
+
+	escapedArgs []string
+	connectInfo ConnectInfo
+	query       []string
+	tls         bool
+	caCertFile  string
+	caCert      string
+	podTLS      PodTLS
+	help        bool
+}
+
+func NewRedisCommander(args []string) (*RedisCommander, error) {
+	c := &RedisCommander{}
+	c.originalArgs = args
+	c.escapedArgs = make([]string, 0)
+	c.connectInfo = ConnectInfo{Port: "6379"}
+	if err := c.parseArgs(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (m *RedisCommander) parseArgs(args []string) error {
+	command := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case len(command) > 0:
+			// Everything after the command name is the arguments of the command
+			command = append(command, arg)
+		case arg == "--help":
+			m.help = true
+			break
+		case arg == "-h":
+			i++
+			m.connectInfo.Server = args[i]
+		case arg == "-p":
+			i++
+			m.connectInfo.Port = args[i]
+		case arg == "-a" || arg == "--pass":
+			i++
+			m.connectInfo.Password = args[i]
+		case arg == "--user":
+			i++
+			m.connectInfo.User = args[i]
+		case arg == "-n":
+			i++
+			m.connectInfo.DbName = args[i]
+		case arg == "-u" || arg == "--url":
+			i++
+			if err := m.parseURI(args[i]); err != nil {
+				return err
+			}
+		case strings.HasPrefix(arg, "--url="):
+			if err := m.parseURI(strings.TrimPrefix(arg, "--url=")); err != nil {
+				return err
+			}
+		case arg == "--tls":
+			m.tls = true
+		case arg == "--cacert":
+			i++
+			m.caCertFile = args[i]
+		case arg == "-f" || arg == "--file":
+			// Commands are read from the file one per line, as with `redis-cli < file`
+			i++
+			data, err := os.ReadFile(args[i])
+			if err != nil {
+				return err
+			}
+			m.query = append(m.query, string(data))
+		case slices.Contains([]string{"-s", "-r", "-i", "-d", "-D", "--sni", "--cacertdir", "--cert", "--key", "--tls-ciphers", "--tls-ciphersuites"}, arg):
+			i++
+			m.escapedArgs = append(m.escapedArgs, arg, fmt.Sprintf("\"%s\"", args[i]))
+		case strings.HasPrefix(arg, "-"):
+			m.escapedArgs = append(m.escapedArgs, arg)
+		default:
+			command = append(command, arg)
+		}
+	}
+
+	if len(command) > 0 {
+		m.query = append(m.query, quoteRedisArgs(command))
+	}
+	if m.caCertFile != "" {
+		data, err := os.ReadFile(m.caCertFile)
+		if err != nil {
+			return fmt.Errorf("failed to read --cacert: %w", err)
+		}
+		m.caCert = string(data)
+	}
+	return nil
+}
+
+// parseURI parses redis://[[user]:pass@]host[:port][/db]. rediss:// enables TLS.
+func (m *RedisCommander) parseURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("invalid redis url: %w", err)
+	}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		m.tls = true
+	default:
+		return fmt.Errorf("not a redis url: %s", uri)
+	}
+
+	ci := ConnectInfo{
+		Server: u.Hostname(),
+		Port:   u.Port(),
+		DbName: strings.TrimPrefix(u.Path, "/"),
+	}
+	if u.User != nil {
+		ci.User = u.User.Username()
+		ci.Password, _ = u.User.Password()
+	}
+	m.connectInfo = m.connectInfo.merge(ci)
+	return nil
+}
+
+// quoteRedisArgs joins the arguments into a line that redis-cli reads from stdin.
+func quoteRedisArgs(args []string) string {
+	quoted := []string{}
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'\\") {
+			arg = fmt.Sprintf("\"%s\"", strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`).Replace(arg))
+		}
+		quoted = append(quoted, arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func (m *RedisCommander) IsInteractive() bool {
+	return len(m.query) == 0 && !m.help
+}
+
+func (m *RedisCommander) ConnectInfo() ConnectInfo {
+	return m.connectInfo
+}
+
+func (m *RedisCommander) SetConnectInfo(connectInfo ConnectInfo) {
+	m.connectInfo = connectInfo
+}
+
+func (m *RedisCommander) Query() string {
+	return strings.Join(m.query, "\n")
+}
+
+func (m *RedisCommander) Files() map[string]string {
+	files := map[string]string{}
+	if m.caCert != "" {
+		files["ca.pem"] = m.caCert
+	}
+	return files
+}
+
+func (m *RedisCommander) HelpCommand() string {
+	return "redis-cli --help"
+}
+
+func (m *RedisCommander) connectionArgs() []string {
+	connectionArgs := []string{
+		"redis-cli",
+		"-h", m.connectInfo.Server,
+		"-p", m.connectInfo.Port,
+	}
+	if m.connectInfo.User != "" {
+		connectionArgs = append(connectionArgs, "--user", "\"$SECRET_DB_USER\"")
+	}
+	if m.connectInfo.DbName != "" {
+		connectionArgs = append(connectionArgs, "-n", m.connectInfo.DbName)
+	}
+	return append(connectionArgs, m.tlsArgs()...)
+}
+
+func (m *RedisCommander) ConfigureTLS(tls PodTLS) error {
+	m.podTLS = tls
+	return nil
+}
+
+// tlsArgs returns the TLS options. The CA file given by --ssl-ca takes precedence over --cacert.
+func (m *RedisCommander) tlsArgs() []string {
+	caFile := m.podTLS.CA
+	if caFile == "" && m.caCert != "" {
+		caFile = "/sql/ca.pem"
+	}
+
+	args := []string{}
+	switch m.podTLS.Mode {
+	case "disable", "allow", "prefer":
+		return args
+	case "require":
+		args = append(args, "--tls", "--insecure")
+	case "verify-ca", "verify-full":
+		args = append(args, "--tls")
+	default:
+		if m.tls || m.podTLS != (PodTLS{}) {
+			args = append(args, "--tls")
+		}
+	}
+	if caFile != "" {
+		args = append(args, "--cacert", caFile)
+	}
+	if m.podTLS.Cert != "" {
+		args = append(args, "--cert", m.podTLS.Cert, "--key", m.podTLS.Key)
+	}
+	return args
+}
+
+func (m *RedisCommander) Command() string {
+	if m.help {
+		return m.HelpCommand()
+	}
+
+	return fmt.Sprintf("%s < %s", strings.Join(slices.Concat(m.connectionArgs(), m.escapedArgs), " "), "/sql/query.sql")
+}
+
+func (m *RedisCommander) InteractiveCommand() string {
+	return strings.Join(slices.Concat(m.connectionArgs(), m.escapedArgs), " ")
+}
+
+func (m *RedisCommander) ContainerImage() string {
+	return "redis:7"
+}
+
+func (m *RedisCommander) SecretEnvKV() map[string]string {
+	kv := map[string]string{
+		"SECRET_DB_USER": "username",
+	}
+	// redis-cli sends AUTH whenever REDISCLI_AUTH is set, even if it is empty
+	if m.connectInfo.Password != "" {
+		kv["REDISCLI_AUTH"] = "password"
+	}
+	return kv
+}
+
+func (m *RedisCommander) CommandType() CommandType {
+	return Redis
+}
+
+// HistoryEnv is the environment variable of the history file of redis-cli.
+func (m *RedisCommander) HistoryEnv() string {
+	return "REDISCLI_HISTFILE"
+}
+
+func (m *RedisCommander) ParseResults(result string) []string {
+	return []string{}
+}
+
+func RedisCommands() *cli.Command {
+	return &cli.Command{
+		Name:            "redis-cli",
+		Usage:           "execute redis-cli commands",
+		ArgsUsage:       "<redis-cli options> [command [arg ...]]",
+		SkipFlagParsing: true,
+		Action:          executeRedisAction,
+	}
+}
+
+func executeRedisAction(c *cli.Context) error {
+	config, err := NewConfig(c)
+	if err != nil {
+		return err
+	}
+	podName, err := CreatePodName("podsql")
+	if err != nil {
+		return err
+	}
+	args := c.Args().Slice()
+	if len(args) == 0 {
+		args = []string{"--help"}
+	}
+	dbCommander, err := NewRedisCommander(args)
+	if err != nil {
+		return err
+	}
+	return runDBCommander(config, podName, dbCommander)
+}
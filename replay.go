@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func ReplayCommands() *cli.Command {
+	return &cli.Command{
+		Name:      "replay",
+		Usage:     "play back a session recorded with --record or the recording settings",
+		ArgsUsage: "<file>",
+		Flags: []cli.Flag{
+			&cli.Float64Flag{
+				Name:    "speed",
+				Aliases: []string{"s"},
+				Usage:   "playback speed",
+				Value:   1,
+			},
+			&cli.Float64Flag{
+				Name:  "idle-time-limit",
+				Usage: "limit the pauses between events to the seconds, 0 for no limit",
+				Value: 2,
+			},
+		},
+		Action: executeReplayAction,
+	}
+}
+
+func executeReplayAction(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("recording file is required")
+	}
+	if c.Float64("speed") <= 0 {
+		return fmt.Errorf("--speed must be positive")
+	}
+	f, err := os.Open(c.Args().First())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return replayRecording(f, os.Stdout, c.Float64("speed"), c.Float64("idle-time-limit"), time.Sleep)
+}
+
+// replayRecording writes the output events of an asciicast v2 recording with their original timing.
+// The input and resize events are skipped.
+func replayRecording(r io.Reader, w io.Writer, speed, idleTimeLimit float64, sleep func(time.Duration)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("recording is empty")
+	}
+	var header asciicastHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return fmt.Errorf("invalid recording header: %w", err)
+	}
+	if header.Version != 2 {
+		return fmt.Errorf("unsupported asciicast version %d", header.Version)
+	}
+
+	last := 0.0
+	for line := 2; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event []any
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || len(event) != 3 {
+			return fmt.Errorf("invalid event at line %d", line)
+		}
+		at, ok1 := event[0].(float64)
+		kind, ok2 := event[1].(string)
+		data, ok3 := event[2].(string)
+		if !ok1 || !ok2 || !ok3 {
+			return fmt.Errorf("invalid event at line %d", line)
+		}
+		if kind != "o" {
+			continue
+		}
+		delay := at - last
+		if idleTimeLimit > 0 && delay > idleTimeLimit {
+			delay = idleTimeLimit
+		}
+		if delay > 0 {
+			sleep(time.Duration(delay / speed * float64(time.Second)))
+		}
+		last = at
+		if _, err := io.WriteString(w, data); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -2,50 +2,132 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"os/signal"
+	"path"
+	"syscall"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 func RunPod(conf *Config, podName string, dbCommander DBCommander) (string, error) {
-	clientset, _, err := newClientset()
+	out, _, err := runQuery(context.Background(), conf, podName, dbCommander)
+	return out, err
+}
+
+// runQuery is RunPod that also returns the exit code of the client. Cancelling ctx stops the query
+// like Ctrl-C, also while the pod is starting.
+func runQuery(ctx context.Context, conf *Config, podName string, dbCommander DBCommander) (string, int, error) {
+	query := dbCommander.Query()
+	if conf.ReadOnly {
+		if err := checkReadOnlyQuery(query, dbCommander.CommandType()); err != nil {
+			return "", -1, err
+		}
+	}
+	if err := confirmDestructiveQuery(conf, dbCommander); err != nil {
+		return "", -1, err
+	}
+
+	record := newAuditRecord(conf, podName, dbCommander)
+	out, exitCode, err := runPod(ctx, conf, podName, dbCommander, record)
+	record.finish(conf, out, exitCode, err)
+	return out, exitCode, err
+}
+
+// runPod runs the query in a bastion pod and returns the output and the exit code of the client.
+func runPod(ctx context.Context, conf *Config, podName string, dbCommander DBCommander, record *AuditRecord) (string, int, error) {
+	query := dbCommander.Query()
+	clientset, config, err := newClientset()
 	if err != nil {
-		return "", fmt.Errorf("failed to create clientset: %w", err)
+		return "", -1, fmt.Errorf("failed to create clientset: %w", err)
+	}
+	if err := checkApproval(context.Background(), clientset, conf, podName, dbCommander, time.Now()); err != nil {
+		return "", -1, err
+	}
+	if ctx.Err() != nil {
+		return "", -1, fmt.Errorf("query was interrupted")
 	}
 
 	cmName := fmt.Sprintf("%s-cm", podName)
 
+	// Queries that do not fit into a ConfigMap together with the shipped files are streamed into the pod after it starts
+	data := shippedFiles(dbCommander)
+	data["query.sql"] = query
+	streamQuery := configMapDataSize(data) > maxConfigMapDataSize
+
 	// Define specifications to create pods
-	podSpec := createRunPodSpec(podName, conf.Namespace, cmName, conf.Timezone, dbCommander)
+	podSpec := createRunPodSpec(podName, cmName, conf, streamQuery, dbCommander)
+	record.annotate(conf, podSpec)
 
 	// create pods
 	podsClient := clientset.CoreV1().Pods(conf.Namespace)
 	pod, err := podsClient.Create(context.Background(), podSpec, metav1.CreateOptions{})
 	if err != nil {
-		return "", fmt.Errorf("failed to create pod: %w", err)
+		return "", -1, fmt.Errorf("failed to create pod: %w", err)
 	}
 
 	// Create ConfigMap to hold queries
 	// Because the -Q option of sqlcmd does not allow queries over 1K to be executed, use ConfigMap to transfer the sql file to the pod and execute it with the -i option.
-	configMap := createConfigMapSpec(cmName, conf.Namespace, pod, map[string]string{"query.sql": dbCommander.Query()})
-	_, err = clientset.CoreV1().ConfigMaps(conf.Namespace).Create(context.Background(), configMap, metav1.CreateOptions{})
-	if err != nil {
-		return "", fmt.Errorf("failed to create configmap: %w", err)
+	if !streamQuery {
+		configMap := createConfigMapSpec(cmName, conf.Namespace, pod, data)
+		_, err = clientset.CoreV1().ConfigMaps(conf.Namespace).Create(context.Background(), configMap, metav1.CreateOptions{})
+		if err != nil {
+			return "", -1, fmt.Errorf("failed to create configmap: %w", err)
+		}
 	}
 
 	// Create Secret for DB_USER and DB_PASSWORD with argument values
 	secret := createBasicAuthSecretSpec(fmt.Sprintf("%s-secret", podName), conf.Namespace, dbCommander.ConnectInfo(), pod)
 	_, err = clientset.CoreV1().Secrets(conf.Namespace).Create(context.Background(), secret, metav1.CreateOptions{})
 	if err != nil {
-		return "", fmt.Errorf("failed to create secret: %w", err)
+		return "", -1, fmt.Errorf("failed to create secret: %w", err)
+	}
+	if err := createTLSSecret(clientset, conf, pod); err != nil {
+		return "", -1, err
+	}
+
+	if err = waitForPodRunning(ctx, podsClient, podName); err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("query was interrupted")
+			if delerr := deletePod(podsClient, podName); delerr != nil {
+				err = fmt.Errorf("%w, failed to delete pod: %w", err, delerr)
+			}
+		}
+		return "", -1, err
+	}
+
+	// Transfer the files before the command starts
+	files := copyInFiles(conf.CopyIn)
+	if streamQuery {
+		files = append(files, podFile{Path: "/sql/query.sql", Data: []byte(query)})
+		for name, data := range shippedFiles(dbCommander) {
+			files = append(files, podFile{Path: path.Join("/sql", name), Data: []byte(data)})
+		}
+	}
+	if len(files) > 0 {
+		files = append(files, podFile{Path: podsqlReadyFile})
+		if err := copyFilesToPod(ctx, clientset, config, conf.Namespace, podName, dbCommander.CommandType().String(), files); err != nil {
+			if delerr := deletePod(podsClient, podName); delerr != nil {
+				return "", -1, fmt.Errorf("%w, failed to delete pod: %w", err, delerr)
+			}
+			return "", -1, err
+		}
 	}
 
-	if err = waitForPodRunning(context.Background(), podsClient, podName); err != nil {
-		return "", err
+	// From here, --timeout and Ctrl-C cancel the statement on the server before the pod is deleted
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if conf.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, conf.Timeout)
+		defer cancel()
 	}
 
 	req := podsClient.GetLogs(podName, &corev1.PodLogOptions{
@@ -54,17 +136,21 @@ func RunPod(conf *Config, podName string, dbCommander DBCommander) (string, erro
 
 	podLogs, err := req.Stream(context.Background())
 	if err != nil {
-		return "", fmt.Errorf("failed to get pod logs: %w", err)
+		return "", -1, fmt.Errorf("failed to get pod logs: %w", err)
 	}
 	defer podLogs.Close()
 
+	// logs is written by the reader until logsDone is closed, so it is only read after that
 	var logs []byte
+	logsDone := make(chan struct{})
 	go func() {
+		defer close(logsDone)
 		buf := make([]byte, 1024)
 		for {
 			n, err := podLogs.Read(buf)
 			if err != nil {
-				if err == io.EOF {
+				// the stream is closed on purpose when the query is aborted
+				if err == io.EOF || ctx.Err() != nil {
 					break
 				}
 
@@ -74,11 +160,32 @@ func RunPod(conf *Config, podName string, dbCommander DBCommander) (string, erro
 			logs = append(logs, buf[:n]...)
 		}
 	}()
+	// abortedLogs stops the reader and returns the logs received before the abort
+	abortedLogs := func() string {
+		podLogs.Close()
+		<-logsDone
+		return string(logs)
+	}
 
+	if len(conf.CopyOut) > 0 {
+		if err := copyOutFiles(ctx, clientset, config, conf, podName, dbCommander); err != nil {
+			if ctx.Err() != nil {
+				return abortedLogs(), -1, abortRunPod(ctx, clientset, config, conf, podName, dbCommander)
+			}
+			if delerr := deletePod(podsClient, podName); delerr != nil {
+				return "", -1, fmt.Errorf("%w, failed to delete pod: %w", err, delerr)
+			}
+			return "", -1, err
+		}
+	}
+
+	var terminated *corev1.Pod
 	waitCh := make(chan struct{})
 	go func() {
 		for {
 			select {
+			case <-ctx.Done():
+				return
 			case <-time.After(1 * time.Second): // Adjust the interval as needed
 				pod, err := podsClient.Get(context.Background(), podName, metav1.GetOptions{})
 				if err != nil {
@@ -86,6 +193,7 @@ func RunPod(conf *Config, podName string, dbCommander DBCommander) (string, erro
 					continue
 				}
 				if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+					terminated = pod
 					close(waitCh)
 					return
 				}
@@ -94,32 +202,81 @@ func RunPod(conf *Config, podName string, dbCommander DBCommander) (string, erro
 	}()
 
 	// Wait for the pod to terminate
-	<-waitCh
+	select {
+	case <-waitCh:
+	case <-ctx.Done():
+		return abortedLogs(), -1, abortRunPod(ctx, clientset, config, conf, podName, dbCommander)
+	}
+	// The log stream ends when the container has terminated
+	<-logsDone
 
 	// Delete the pod
 	if err := deletePod(podsClient, podName); err != nil {
-		return "", fmt.Errorf("failed to delete pod: %w", err)
+		return "", -1, fmt.Errorf("failed to delete pod: %w", err)
 	}
-	return string(logs), nil
+	return string(logs), containerExitCode(terminated, dbCommander.CommandType().String()), nil
 }
 
-func createRunPodSpec(podName, namespace, cmName, timezone string, dbCommander DBCommander) *corev1.Pod {
-	return &corev1.Pod{
+// abortRunPod cancels the running statement after --timeout or Ctrl-C and deletes the pod.
+func abortRunPod(ctx context.Context, clientset kubernetes.Interface, config *rest.Config, conf *Config, podName string, dbCommander DBCommander) error {
+	err := fmt.Errorf("query was interrupted")
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("query timed out after %s", conf.Timeout)
+	}
+	if cancelerr := cancelStatement(clientset, config, conf.Namespace, podName, dbCommander); cancelerr != nil {
+		err = fmt.Errorf("%w, %w", err, cancelerr)
+	}
+	if delerr := deletePod(clientset.CoreV1().Pods(conf.Namespace), podName); delerr != nil {
+		err = fmt.Errorf("%w, failed to delete pod: %w", err, delerr)
+	}
+	return err
+}
+
+// copyOutFiles waits for the command to finish, copies the requested files to the local machine
+// and then lets the container terminate.
+func copyOutFiles(ctx context.Context, clientset kubernetes.Interface, config *rest.Config, conf *Config, podName string, dbCommander DBCommander) error {
+	container := dbCommander.CommandType().String()
+	if err := waitForCommandExit(ctx, clientset, config, conf.Namespace, podName, container); err != nil {
+		return fmt.Errorf("failed to wait for command: %w", err)
+	}
+	for _, mapping := range conf.CopyOut {
+		if err := copyFileFromPod(ctx, clientset, config, conf.Namespace, podName, container, mapping); err != nil {
+			return err
+		}
+	}
+	return releaseContainer(ctx, clientset, config, conf.Namespace, podName, container)
+}
+
+func createRunPodSpec(podName, cmName string, conf *Config, streamQuery bool, dbCommander DBCommander) *corev1.Pod {
+	queryVolumeSource := corev1.VolumeSource{
+		ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{
+				Name: cmName,
+			},
+		},
+	}
+	command := dbCommander.Command()
+	if streamQuery {
+		queryVolumeSource = corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
+	}
+	if streamQuery || len(conf.CopyIn) > 0 {
+		command = waitForReadyCommand(command)
+	}
+	if len(conf.CopyOut) > 0 {
+		command = holdAfterCommand(command)
+	}
+
+	pod := customizePod(&corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      podName,
-			Namespace: namespace,
+			Namespace: conf.Namespace,
 		},
 		Spec: corev1.PodSpec{
+			ServiceAccountName: conf.ServiceAccount,
 			Volumes: []corev1.Volume{
 				{
-					Name: "query-volume",
-					VolumeSource: corev1.VolumeSource{
-						ConfigMap: &corev1.ConfigMapVolumeSource{
-							LocalObjectReference: corev1.LocalObjectReference{
-								Name: cmName,
-							},
-						},
-					},
+					Name:         "query-volume",
+					VolumeSource: queryVolumeSource,
 				},
 			},
 			Containers: []corev1.Container{
@@ -127,13 +284,16 @@ func createRunPodSpec(podName, namespace, cmName, timezone string, dbCommander D
 					Name:         dbCommander.CommandType().String(),
 					Image:        dbCommander.ContainerImage(),
 					VolumeMounts: []corev1.VolumeMount{{Name: "query-volume", MountPath: "/sql"}},
-					Env:          append(generateSecretEnvVars(podName, dbCommander), corev1.EnvVar{Name: "TZ", Value: timezone}),
-					Command:      []string{"/bin/sh", "-c", dbCommander.Command()},
+					Env:          append(generateSecretEnvVars(podName, dbCommander), corev1.EnvVar{Name: "TZ", Value: conf.Timezone}),
+					Command:      []string{"/bin/sh", "-c", command},
 				},
 			},
 			RestartPolicy: corev1.RestartPolicyNever,
 		},
-	}
+	}, dbCommander)
+	mountTLSSecret(pod, conf.TLS)
+	setActiveDeadline(pod, conf.Timeout)
+	return pod
 }
 
 func createConfigMapSpec(cmName, namespace string, pod *corev1.Pod, data map[string]string) *corev1.ConfigMap {
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AWSSecretsManagerConfig locates the credentials in AWS Secrets Manager.
+// The secret is either a JSON object like the ones RDS manages (username, password, host, port, dbname) or the password itself.
+type AWSSecretsManagerConfig struct {
+	SecretID string `yaml:"secretId"`
+	Region   string `yaml:"region"`
+	// Endpoint overrides https://secretsmanager.<region>.amazonaws.com, e.g. for VPC endpoints
+	Endpoint string `yaml:"endpoint"`
+}
+
+type awsSecretsManagerCredentialResolver struct {
+	config      AWSSecretsManagerConfig
+	credentials func() (AWSCredentials, error)
+	now         func() time.Time
+	client      *http.Client
+}
+
+func newAWSSecretsManagerCredentialResolver(c AWSSecretsManagerConfig) *awsSecretsManagerCredentialResolver {
+	if c.Region == "" {
+		c.Region = AWSRegion()
+	}
+	if c.Endpoint == "" && c.Region != "" {
+		c.Endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", c.Region)
+	}
+	return &awsSecretsManagerCredentialResolver{
+		config:      c,
+		credentials: LoadAWSCredentials,
+		now:         time.Now,
+		client:      http.DefaultClient,
+	}
+}
+
+func (r *awsSecretsManagerCredentialResolver) Resolve(ctx context.Context) (ConnectInfo, error) {
+	if r.config.SecretID == "" || r.config.Region == "" {
+		return ConnectInfo{}, fmt.Errorf("secretId and region are required for AWS Secrets Manager")
+	}
+	creds, err := r.credentials()
+	if err != nil {
+		return ConnectInfo{}, fmt.Errorf("failed to load AWS credentials: %w", err)
+	}
+
+	body, err := json.Marshal(map[string]string{"SecretId": r.config.SecretID})
+	if err != nil {
+		return ConnectInfo{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(r.config.Endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return ConnectInfo{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signSigV4(req, body, creds, r.config.Region, "secretsmanager", r.now())
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return ConnectInfo{}, fmt.Errorf("failed to get secret value: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ConnectInfo{}, fmt.Errorf("failed to get secret value: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ConnectInfo{}, fmt.Errorf("failed to get secret value of %s: %s: %s", r.config.SecretID, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	var value struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(respBody, &value); err != nil {
+		return ConnectInfo{}, fmt.Errorf("invalid GetSecretValue response: %w", err)
+	}
+	if value.SecretString == "" {
+		return ConnectInfo{}, fmt.Errorf("secret %s has no SecretString", r.config.SecretID)
+	}
+	return parseSecretString(value.SecretString), nil
+}
+
+// parseSecretString reads the RDS secret format, or takes the whole string as the password.
+func parseSecretString(s string) ConnectInfo {
+	var secret map[string]any
+	if err := json.Unmarshal([]byte(s), &secret); err != nil {
+		return ConnectInfo{Password: s}
+	}
+	str := func(key string) string {
+		switch v := secret[key].(type) {
+		case string:
+			return v
+		case float64:
+			return fmt.Sprintf("%.0f", v)
+		default:
+			return ""
+		}
+	}
+	return ConnectInfo{
+		Server:   str("host"),
+		Port:     str("port"),
+		User:     str("username"),
+		Password: str("password"),
+		DbName:   str("dbname"),
+	}
+}
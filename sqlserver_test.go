@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSqlServerDumpCommands(t *testing.T) {
+	tests := []struct {
+		name      string
+		restore   bool
+		opts      DumpOptions
+		want      []string
+		wantImage string
+		wantErr   bool
+	}{
+		{name: "bcp out", opts: DumpOptions{Tables: []string{"dbo.users"}},
+			want: []string{`bcp "dbo.users" out /dev/stdout -o /dev/stderr -c`}, wantImage: "mcr.microsoft.com/mssql-tools"},
+		{name: "bcp in", restore: true, opts: DumpOptions{Tables: []string{"dbo.users"}},
+			want: []string{`bcp "dbo.users" in /dev/stdin -o /dev/stderr -c`}, wantImage: "mcr.microsoft.com/mssql-tools"},
+		{name: "bacpac export", opts: DumpOptions{},
+			want: []string{"microsoft.sqlpackage --version " + sqlpackageVersion, "/Action:Export", "/SourceServerName:db.example.com,1433",
+				"/SourceDatabaseName:app", `/SourcePassword:"$SECRET_DB_PASSWORD"`, ">&2 && cat " + bacpacFile}, wantImage: sqlpackageImage},
+		{name: "bacpac export of tables", opts: DumpOptions{Tables: []string{"dbo.users", "dbo.orders"}},
+			want: []string{`"/p:TableData=dbo.users" "/p:TableData=dbo.orders"`}, wantImage: sqlpackageImage},
+		{name: "bacpac import", restore: true, opts: DumpOptions{},
+			want: []string{"cat > " + bacpacFile + " && ", "/Action:Import", "/TargetDatabaseName:app"}, wantImage: sqlpackageImage},
+		{name: "exclude tables", opts: DumpOptions{ExcludeTables: []string{"dbo.logs"}}, wantErr: true},
+		{name: "import of tables", restore: true, opts: DumpOptions{Tables: []string{"dbo.users", "dbo.orders"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewSqlServerCommander([]string{"-S", "db.example.com,1433", "-U", "admin", "-P", "secret", "-d", "app"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var command string
+			if tt.restore {
+				command, err = m.RestoreCommand(tt.opts)
+			} else {
+				command, err = m.DumpCommand(tt.opts)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("command = %s, want an error", command)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, part := range tt.want {
+				if !strings.Contains(command, part) {
+					t.Errorf("command = %s, want %s in it", command, part)
+				}
+			}
+			if image := m.ContainerImage(); image != tt.wantImage {
+				t.Errorf("ContainerImage() = %s, want %s", image, tt.wantImage)
+			}
+		})
+	}
+}
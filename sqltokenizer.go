@@ -0,0 +1,343 @@
+package main
+
+import (
+	"strings"
+)
+
+type sqlTokenKind int
+
+const (
+	sqlWord sqlTokenKind = iota
+	// sqlIdentifier is a quoted identifier such as "order", `order` or [order], which is never a keyword
+	sqlIdentifier
+	sqlString
+	sqlPunct
+	// sqlSeparator ends a statement: a semicolon, or a GO line of sqlcmd
+	sqlSeparator
+	// sqlMetaCommand is a line interpreted by the client, such as \d of psql or :setvar of sqlcmd
+	sqlMetaCommand
+)
+
+type sqlToken struct {
+	kind sqlTokenKind
+	// text is upper-cased for words
+	text string
+	// pos and end are the byte offsets of the token in the query
+	pos int
+	end int
+}
+
+// sqlStatement is a statement of a query split by the tokenizer.
+type sqlStatement struct {
+	tokens []sqlToken
+	// text is the statement as written in the query, without the separator
+	text string
+	// inline is set for a client command that follows a query on the same line, such as SELECT ... \gexec
+	inline bool
+}
+
+// keyword returns the upper-cased i-th word of the statement, or "" if there is none.
+func (s sqlStatement) keyword(i int) string {
+	for _, t := range s.tokens {
+		if t.kind != sqlWord {
+			continue
+		}
+		if i == 0 {
+			return t.text
+		}
+		i--
+	}
+	return ""
+}
+
+// sqlTokenizer splits queries into tokens, skipping comments and the contents of string literals.
+// The dialect decides the client-side commands and the comment, quoting and escape syntaxes:
+//   - MySQL: # comments, /*! executable comments */, backslash escapes, "strings" and `identifiers`
+//   - PostgreSQL: nested /* */ comments, backslash escapes in E'strings', $tag$dollar quotes$tag$, "identifiers" and \ meta-commands
+//   - SQL Server: [identifiers], "identifiers", : commands and GO separators
+//   - ClickHouse: `identifiers` and "identifiers"
+type sqlTokenizer struct {
+	dialect CommandType
+	src     string
+	pos     int
+	tokens  []sqlToken
+	// execComment is set inside a MySQL /*! */ comment, whose body the server runs
+	execComment bool
+}
+
+func tokenizeSQL(query string, dialect CommandType) []sqlToken {
+	t := &sqlTokenizer{dialect: dialect, src: query}
+	t.run()
+	return t.tokens
+}
+
+// splitSQLStatements tokenizes the query and groups the tokens into statements. Empty statements are dropped.
+func splitSQLStatements(query string, dialect CommandType) []sqlStatement {
+	statements := []sqlStatement{}
+	current := sqlStatement{}
+	flush := func() {
+		if len(current.tokens) > 0 {
+			current.text = query[current.tokens[0].pos:current.tokens[len(current.tokens)-1].end]
+			statements = append(statements, current)
+		}
+		current = sqlStatement{}
+	}
+	for _, tok := range tokenizeSQL(query, dialect) {
+		switch tok.kind {
+		case sqlSeparator:
+			flush()
+		case sqlMetaCommand:
+			flush()
+			current.tokens = append(current.tokens, tok)
+			current.inline = !isLineStart(query, tok.pos)
+			flush()
+		default:
+			current.tokens = append(current.tokens, tok)
+		}
+	}
+	flush()
+	return statements
+}
+
+// emit adds the token from start to the current position.
+func (t *sqlTokenizer) emit(kind sqlTokenKind, text string, start int) {
+	t.tokens = append(t.tokens, sqlToken{kind: kind, text: text, pos: start, end: t.pos})
+}
+
+func (t *sqlTokenizer) peek(offset int) byte {
+	if t.pos+offset < len(t.src) {
+		return t.src[t.pos+offset]
+	}
+	return 0
+}
+
+func (t *sqlTokenizer) atLineStart() bool {
+	return isLineStart(t.src, t.pos)
+}
+
+// isLineStart reports whether only spaces precede the position in its line.
+func isLineStart(src string, pos int) bool {
+	for i := pos - 1; i >= 0; i-- {
+		switch src[i] {
+		case ' ', '\t', '\r':
+			continue
+		case '\n':
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (t *sqlTokenizer) restOfLine() string {
+	end := strings.IndexByte(t.src[t.pos:], '\n')
+	if end < 0 {
+		end = len(t.src) - t.pos
+	}
+	line := t.src[t.pos : t.pos+end]
+	t.pos += end
+	return strings.TrimSpace(line)
+}
+
+func (t *sqlTokenizer) run() {
+	for t.pos < len(t.src) {
+		c := t.src[t.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			t.pos++
+		case t.scanMetaCommand():
+		case c == '-' && t.peek(1) == '-':
+			t.restOfLine()
+		case c == '#' && t.dialect == MySQL:
+			t.restOfLine()
+		case c == '/' && t.peek(1) == '*' && t.dialect == MySQL && t.scanExecComment():
+		case c == '*' && t.peek(1) == '/' && t.execComment:
+			t.pos += 2
+			t.execComment = false
+		case c == '/' && t.peek(1) == '*':
+			t.skipBlockComment()
+		case c == '\'':
+			t.scanString('\'')
+		case c == '"' && t.dialect == MySQL:
+			t.scanString('"')
+		case c == '"':
+			t.scanQuotedIdentifier('"')
+		case c == '`' && (t.dialect == MySQL || t.dialect == ClickHouse):
+			t.scanQuotedIdentifier('`')
+		case c == '[' && t.dialect == SQLCmd:
+			t.scanQuotedIdentifier(']')
+		case c == '$' && t.dialect == PostgreSQL && t.scanDollarQuote():
+		case c == ';':
+			start := t.pos
+			t.pos++
+			t.emit(sqlSeparator, ";", start)
+		case isSQLWordChar(c):
+			start := t.pos
+			for t.pos < len(t.src) && isSQLWordChar(t.src[t.pos]) {
+				t.pos++
+			}
+			t.emit(sqlWord, strings.ToUpper(t.src[start:t.pos]), start)
+		default:
+			start := t.pos
+			t.pos++
+			t.emit(sqlPunct, string(c), start)
+		}
+	}
+}
+
+// scanMetaCommand consumes the rest of the line if it is a command of the client. psql takes a backslash
+// anywhere outside of literals as a command, such as \gexec after a query, while sqlcmd only at the start of a line.
+func (t *sqlTokenizer) scanMetaCommand() bool {
+	start := t.pos
+	c := t.src[t.pos]
+	if t.dialect == PostgreSQL && c == '\\' {
+		t.emit(sqlMetaCommand, t.restOfLine(), start)
+		return true
+	}
+	if !t.atLineStart() {
+		return false
+	}
+	switch t.dialect {
+	case SQLCmd:
+		if c == ':' {
+			t.emit(sqlMetaCommand, t.restOfLine(), start)
+			return true
+		}
+		// GO [count] separates the batches
+		rest := t.src[t.pos:]
+		if len(rest) >= 2 && strings.EqualFold(rest[:2], "GO") && (len(rest) == 2 || !isSQLWordChar(rest[2])) {
+			t.restOfLine()
+			t.emit(sqlSeparator, "GO", start)
+			return true
+		}
+	}
+	return false
+}
+
+// scanExecComment enters a /*! */ or /*!50700 */ comment, or the /*M! */ of MariaDB, whose body is tokenized
+// as SQL because the server runs it. The opening is emitted as a token so that the checks can see the comment.
+func (t *sqlTokenizer) scanExecComment() bool {
+	i := t.pos + 2
+	if i < len(t.src) && t.src[i] == 'M' {
+		i++
+	}
+	if i >= len(t.src) || t.src[i] != '!' {
+		return false
+	}
+	start := t.pos
+	// the version the body requires, e.g. 50700
+	i++
+	for i < len(t.src) && '0' <= t.src[i] && t.src[i] <= '9' {
+		i++
+	}
+	t.pos = i
+	t.emit(sqlPunct, "/*!", start)
+	t.execComment = true
+	return true
+}
+
+// skipBlockComment skips a /* */ comment, which PostgreSQL allows to nest.
+func (t *sqlTokenizer) skipBlockComment() {
+	depth := 0
+	for t.pos < len(t.src) {
+		switch {
+		case t.src[t.pos] == '/' && t.peek(1) == '*':
+			if depth == 0 || t.dialect == PostgreSQL {
+				depth++
+			}
+			t.pos += 2
+		case t.src[t.pos] == '*' && t.peek(1) == '/':
+			depth--
+			t.pos += 2
+			if depth == 0 {
+				return
+			}
+		default:
+			t.pos++
+		}
+	}
+}
+
+// scanString consumes a literal quoted with quote, where a doubled quote is an escaped quote.
+// MySQL and the E'strings' of PostgreSQL also accept backslash escapes.
+func (t *sqlTokenizer) scanString(quote byte) {
+	start := t.pos
+	backslash := t.dialect == MySQL ||
+		(t.dialect == PostgreSQL && start > 0 && (t.src[start-1] == 'E' || t.src[start-1] == 'e'))
+	t.pos++
+	for t.pos < len(t.src) {
+		c := t.src[t.pos]
+		switch {
+		case c == '\\' && backslash:
+			t.pos += 2
+			continue
+		case c == quote && t.peek(1) == quote:
+			t.pos += 2
+			continue
+		case c == quote:
+			t.pos++
+			t.emit(sqlString, t.src[start:t.pos], start)
+			return
+		}
+		t.pos++
+	}
+	t.pos = len(t.src)
+	t.emit(sqlString, t.src[start:], start)
+}
+
+// scanQuotedIdentifier consumes an identifier quoted up to closing, where a doubled closing character is escaped.
+func (t *sqlTokenizer) scanQuotedIdentifier(closing byte) {
+	start := t.pos
+	t.pos++
+	var name strings.Builder
+	for t.pos < len(t.src) {
+		c := t.src[t.pos]
+		if c == closing {
+			if t.peek(1) == closing {
+				name.WriteByte(c)
+				t.pos += 2
+				continue
+			}
+			t.pos++
+			break
+		}
+		name.WriteByte(c)
+		t.pos++
+	}
+	t.emit(sqlIdentifier, name.String(), start)
+}
+
+// scanDollarQuote consumes a dollar-quoted string such as $$...$$ or $body$...$body$.
+// It returns false for $1 and other uses of $ that do not start a dollar quote.
+func (t *sqlTokenizer) scanDollarQuote() bool {
+	start := t.pos
+	// the tag does not start with a digit, and cannot follow an identifier, as in a$b
+	if start > 0 && isSQLWordChar(t.src[start-1]) {
+		return false
+	}
+	i := start + 1
+	if i < len(t.src) && '0' <= t.src[i] && t.src[i] <= '9' {
+		return false
+	}
+	for i < len(t.src) && t.src[i] != '$' && isSQLWordChar(t.src[i]) {
+		i++
+	}
+	if i >= len(t.src) || t.src[i] != '$' {
+		return false
+	}
+	tag := t.src[start : i+1]
+	end := strings.Index(t.src[i+1:], tag)
+	if end < 0 {
+		t.pos = len(t.src)
+	} else {
+		t.pos = i + 1 + end + len(tag)
+	}
+	t.emit(sqlString, t.src[start:t.pos], start)
+	return true
+}
+
+func isSQLWordChar(c byte) bool {
+	return c == '_' || c == '$' || c == '@' || c == '#' ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || c >= 0x80
+}
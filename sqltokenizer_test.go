@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// formatTokens renders the tokens as kind:text, e.g. W:SELECT S:'a' P:(.
+func formatTokens(tokens []sqlToken) string {
+	kinds := map[sqlTokenKind]string{
+		sqlWord: "W", sqlIdentifier: "I", sqlString: "S", sqlPunct: "P", sqlSeparator: "sep", sqlMetaCommand: "M",
+	}
+	parts := []string{}
+	for _, t := range tokens {
+		parts = append(parts, fmt.Sprintf("%s:%s", kinds[t.kind], t.text))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestTokenizeSQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect CommandType
+		query   string
+		want    string
+	}{
+		{name: "words are upper-cased", dialect: MySQL, query: "select id from t", want: "W:SELECT W:ID W:FROM W:T"},
+		{name: "line comment", dialect: PostgreSQL, query: "SELECT 1 -- DROP TABLE t\n;", want: "W:SELECT W:1 sep:;"},
+		{name: "mysql hash comment", dialect: MySQL, query: "SELECT 1 # DROP TABLE t", want: "W:SELECT W:1"},
+		{name: "hash is a word elsewhere", dialect: SQLCmd, query: "SELECT * FROM #tmp", want: "W:SELECT P:* W:FROM W:#TMP"},
+		{name: "block comment", dialect: MySQL, query: "SELECT /* DROP; */ 1", want: "W:SELECT W:1"},
+		{name: "nested block comment", dialect: PostgreSQL, query: "SELECT /* a /* DROP */ b; */ 1", want: "W:SELECT W:1"},
+		{name: "block comments do not nest in mysql", dialect: MySQL, query: "SELECT /* a /* b */ 1", want: "W:SELECT W:1"},
+		{name: "mysql executable comment", dialect: MySQL, query: "SELECT /*!40001 SQL_NO_CACHE */ 1", want: "W:SELECT P:/*! W:SQL_NO_CACHE W:1"},
+		{name: "mariadb executable comment", dialect: MySQL, query: "/*M!100100 DROP TABLE t */", want: "P:/*! W:DROP W:TABLE W:T"},
+		{name: "executable comment elsewhere", dialect: PostgreSQL, query: "SELECT /*! DROP */ 1", want: "W:SELECT W:1"},
+		{name: "string with doubled quote", dialect: PostgreSQL, query: "SELECT 'it''s; DROP'", want: "W:SELECT S:'it''s; DROP'"},
+		{name: "mysql backslash escape", dialect: MySQL, query: `SELECT 'a\'; DROP' , 1`, want: `W:SELECT S:'a\'; DROP' P:, W:1`},
+		{name: "mysql double-quoted string", dialect: MySQL, query: `SELECT "DROP; x"`, want: `W:SELECT S:"DROP; x"`},
+		{name: "postgres escape string", dialect: PostgreSQL, query: `SELECT E'a\'; DROP'`, want: `W:SELECT W:E S:'a\'; DROP'`},
+		{name: "postgres standard string", dialect: PostgreSQL, query: `SELECT 'a\'; DROP`, want: `W:SELECT S:'a\' sep:; W:DROP`},
+		{name: "double-quoted identifier", dialect: PostgreSQL, query: `SELECT "Dele""te" FROM t`, want: `W:SELECT I:Dele"te W:FROM W:T`},
+		{name: "backtick identifier", dialect: MySQL, query: "SELECT `drop``x`", want: "W:SELECT I:drop`x"},
+		{name: "clickhouse backtick identifier", dialect: ClickHouse, query: "SELECT `delete`", want: "W:SELECT I:delete"},
+		{name: "bracket identifier", dialect: SQLCmd, query: "SELECT [drop]]x] FROM t", want: "W:SELECT I:drop]x W:FROM W:T"},
+		{name: "dollar quote", dialect: PostgreSQL, query: "SELECT $$DROP; TABLE$$", want: "W:SELECT S:$$DROP; TABLE$$"},
+		{name: "tagged dollar quote", dialect: PostgreSQL, query: "DO $body$ BEGIN $$x$$; END $body$", want: "W:DO S:$body$ BEGIN $$x$$; END $body$"},
+		{name: "positional parameter", dialect: PostgreSQL, query: "SELECT $1", want: "W:SELECT W:$1"},
+		{name: "dollar inside an identifier", dialect: PostgreSQL, query: "SELECT a$b$ FROM t", want: "W:SELECT W:A$B$ W:FROM W:T"},
+		{name: "psql meta command", dialect: PostgreSQL, query: "\\dt public.*\nSELECT 1", want: "M:\\dt public.* W:SELECT W:1"},
+		{name: "psql command after a query", dialect: PostgreSQL, query: "SELECT 1 \\gexec", want: "W:SELECT W:1 M:\\gexec"},
+		{name: "sqlcmd command and GO", dialect: SQLCmd, query: ":setvar x 1\nSELECT 1\ngo 2\nSELECT 2", want: "M::setvar x 1 W:SELECT W:1 sep:GO W:SELECT W:2"},
+		{name: "GO inside a line", dialect: SQLCmd, query: "SELECT go FROM t", want: "W:SELECT W:GO W:FROM W:T"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatTokens(tokenizeSQL(tt.query, tt.dialect)); got != tt.want {
+				t.Errorf("tokenizeSQL(%q) =\n  %s\nwant\n  %s", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitSQLStatements(t *testing.T) {
+	query := "SELECT 1; ;\n-- comment\nUPDATE t SET a = ';' WHERE id = 1 ;\n\\x\nSELECT 2 \\g out.txt"
+	statements := splitSQLStatements(query, PostgreSQL)
+	want := []struct {
+		text   string
+		inline bool
+	}{
+		{text: "SELECT 1"},
+		{text: "UPDATE t SET a = ';' WHERE id = 1"},
+		{text: `\x`},
+		{text: "SELECT 2"},
+		{text: `\g out.txt`, inline: true},
+	}
+	if len(statements) != len(want) {
+		t.Fatalf("splitSQLStatements() returned %d statements, want %d", len(statements), len(want))
+	}
+	for i, s := range statements {
+		if s.text != want[i].text || s.inline != want[i].inline {
+			t.Errorf("statement %d = %q (inline %v), want %q (inline %v)", i, s.text, s.inline, want[i].text, want[i].inline)
+		}
+	}
+}
+
+func TestHasWhereClause(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect CommandType
+		query   string
+		want    bool
+	}{
+		{name: "where", dialect: MySQL, query: "DELETE FROM t WHERE id = 1", want: true},
+		{name: "no where", dialect: MySQL, query: "DELETE FROM t", want: false},
+		{name: "where in a subquery", dialect: MySQL, query: "DELETE FROM t USING t JOIN (SELECT id FROM u WHERE x) s", want: false},
+		{name: "where after a subquery", dialect: PostgreSQL, query: "UPDATE t SET a = (SELECT max(a) FROM u) WHERE id = 1", want: true},
+		{name: "where outside the cte", dialect: PostgreSQL, query: "WITH d AS (DELETE FROM t) SELECT * FROM d WHERE x", want: false},
+		{name: "where inside the cte", dialect: PostgreSQL, query: "WITH d AS (DELETE FROM t WHERE x) SELECT * FROM d", want: true},
+		{name: "where in a comment", dialect: MySQL, query: "DELETE FROM t /* WHERE id = 1 */", want: false},
+		{name: "where in a string", dialect: PostgreSQL, query: "UPDATE t SET note = 'WHERE'", want: false},
+		{name: "where as an identifier", dialect: SQLCmd, query: "UPDATE t SET [where] = 1", want: false},
+		{name: "where in a dollar quote", dialect: PostgreSQL, query: "UPDATE t SET body = $$ WHERE $$", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := tokenizeSQL(tt.query, tt.dialect)
+			i := 0
+			for i < len(tokens) && tokens[i].text != "DELETE" && tokens[i].text != "UPDATE" {
+				i++
+			}
+			if i == len(tokens) {
+				t.Fatalf("no DELETE or UPDATE in %q", tt.query)
+			}
+			if got := hasWhereClause(tokens[i+1:]); got != tt.want {
+				t.Errorf("hasWhereClause(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
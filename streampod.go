@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// StreamPod launches a bastion pod and runs the command in it with stdin and stdout connected to the local streams,
+// so that archives are transferred without being stored in the container.
+func StreamPod(conf *Config, podName string, dbCommander DBCommander, command string, stdin io.Reader, stdout io.Writer) error {
+	// The record holds the dump or restore command instead of a query, without the data
+	record := newAuditRecord(conf, podName, dbCommander)
+	if record != nil {
+		record.Interactive = false
+		record.Query = command
+	}
+	err := streamPod(conf, podName, dbCommander, command, stdin, stdout, record)
+	record.finish(conf, "", exitCodeOf(err), err)
+	return err
+}
+
+func streamPod(conf *Config, podName string, dbCommander DBCommander, command string, stdin io.Reader, stdout io.Writer, record *AuditRecord) error {
+	clientset, config, err := newClientset()
+	if err != nil {
+		return fmt.Errorf("failed to create clientset: %w", err)
+	}
+	if err := checkApproval(context.Background(), clientset, conf, podName, dbCommander, time.Now()); err != nil {
+		return err
+	}
+
+	// Define specifications to create pods
+	podSpec := createExecPodSpec(podName, conf, dbCommander)
+	record.annotate(conf, podSpec)
+
+	// create pods
+	podsClient := clientset.CoreV1().Pods(conf.Namespace)
+	pod, err := podsClient.Create(context.Background(), podSpec, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create pod: %w", err)
+	}
+
+	// Create ConfigMap to hold the files the client needs
+	if files := shippedFiles(dbCommander); len(files) > 0 {
+		configMap := createConfigMapSpec(fmt.Sprintf("%s-cm", podName), conf.Namespace, pod, files)
+		_, err = clientset.CoreV1().ConfigMaps(conf.Namespace).Create(context.Background(), configMap, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to create configmap: %w", err)
+		}
+	}
+
+	// Create Secret for DB_USER and DB_PASSWORD with argument values
+	secret := createBasicAuthSecretSpec(fmt.Sprintf("%s-secret", podName), conf.Namespace, dbCommander.ConnectInfo(), pod)
+	_, err = clientset.CoreV1().Secrets(conf.Namespace).Create(context.Background(), secret, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create secret: %w", err)
+	}
+	if err := createTLSSecret(clientset, conf, pod); err != nil {
+		return err
+	}
+
+	if err = waitForPodRunning(context.Background(), podsClient, podName); err != nil {
+		return err
+	}
+
+	err = execInPod(context.Background(), clientset, config, conf.Namespace, podName, dbCommander.CommandType().String(),
+		[]string{"/bin/sh", "-c", command}, stdin, stdout, os.Stderr)
+	if err != nil {
+		if delerr := deletePod(podsClient, podName); delerr != nil {
+			return fmt.Errorf("failed to execute command: %w, failed to delete pod: %w", err, delerr)
+		}
+		return fmt.Errorf("failed to execute command: %w", err)
+	}
+
+	// Delete the pod
+	if err := deletePod(podsClient, podName); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// podDeadlineMargin is added to --timeout for activeDeadlineSeconds of the pod, which also counts the startup of the pod.
+// Until then, podsql cancels the statement itself, so the pod deadline only stops pods that podsql left behind.
+const podDeadlineMargin = 2 * time.Minute
+
+// cancelTimeout bounds the exec that cancels the running statement.
+const cancelTimeout = 30 * time.Second
+
+// TimeoutConfigurer is implemented by DBCommanders whose server can abort the statements running longer than the timeout.
+type TimeoutConfigurer interface {
+	ConfigureTimeout(timeout time.Duration) error
+}
+
+// configureTimeout passes --timeout to the server. The clients without a statement timeout
+// are still stopped by the cancellation and the pod deadline.
+func configureTimeout(conf *Config, dbCommander DBCommander) error {
+	if conf.Timeout <= 0 {
+		return nil
+	}
+	if c, ok := dbCommander.(TimeoutConfigurer); ok {
+		return c.ConfigureTimeout(conf.Timeout)
+	}
+	return nil
+}
+
+// timeoutSeconds rounds the timeout up to whole seconds for the settings that take seconds.
+func timeoutSeconds(timeout time.Duration) int64 {
+	return int64(math.Ceil(timeout.Seconds()))
+}
+
+func setActiveDeadline(pod *corev1.Pod, timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	deadline := timeoutSeconds(timeout + podDeadlineMargin)
+	pod.Spec.ActiveDeadlineSeconds = &deadline
+}
+
+// clientProcesses are the process names of the clients in the containers.
+var clientProcesses = map[CommandType][]string{
+	MySQL:      {"mysql", "mariadb"},
+	PostgreSQL: {"psql"},
+	SQLCmd:     {"sqlcmd"},
+	Oracle:     {"sqlplus"},
+	MongoDB:    {"mongosh"},
+	Redis:      {"redis-cli"},
+	ClickHouse: {"clickhouse", "clickhouse-client"},
+}
+
+// cancelCommand sends SIGINT to the client, which cancels the running statement on the server like Ctrl-C does:
+// psql sends a cancel request as pg_cancel_backend does, mysql runs KILL QUERY, sqlcmd sends an attention
+// and clickhouse-client sends a cancel packet. The command waits a moment for the cancel to reach the server,
+// because the pod is deleted right after. It uses /proc because pkill is missing in some images.
+func cancelCommand(commandType CommandType) string {
+	return fmt.Sprintf(`for p in /proc/[0-9]*; do case "$(cat "$p/comm" 2>/dev/null)" in %s) kill -INT "${p#/proc/}";; esac; done; sleep 2`,
+		strings.Join(clientProcesses[commandType], "|"))
+}
+
+// cancelStatement cancels the statement that the client is running in the pod from a second exec.
+func cancelStatement(clientset kubernetes.Interface, config *rest.Config, namespace, podName string, dbCommander DBCommander) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+	defer cancel()
+	command := []string{"/bin/sh", "-c", cancelCommand(dbCommander.CommandType())}
+	if err := execInPod(ctx, clientset, config, namespace, podName, dbCommander.CommandType().String(), command, nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to cancel statement: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	tlsMountPath = "/podsql-tls"
+
+	tlsCAKey      = "ca.crt"
+	tlsCertKey    = "tls.crt"
+	tlsKeyKey     = "tls.key"
+	tlsCertKeyKey = "tls.pem"
+)
+
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// TLSConfig holds the TLS options given by the flags or the profile.
+// The files are local paths, which are uploaded into a Secret mounted in the pod.
+type TLSConfig struct {
+	SSLMode string `yaml:"sslMode"`
+	SSLCA   string `yaml:"sslCA"`
+	SSLCert string `yaml:"sslCert"`
+	SSLKey  string `yaml:"sslKey"`
+}
+
+func (t TLSConfig) validate() error {
+	if t.SSLMode != "" && !slices.Contains(sslModes, t.SSLMode) {
+		return fmt.Errorf("invalid --ssl-mode: %s must be one of %v", t.SSLMode, sslModes)
+	}
+	if (t.SSLCert == "") != (t.SSLKey == "") {
+		return fmt.Errorf("--ssl-cert and --ssl-key must be given together")
+	}
+	for _, p := range []string{t.SSLCA, t.SSLCert, t.SSLKey} {
+		if p == "" {
+			continue
+		}
+		if fi, err := os.Stat(p); err != nil {
+			return fmt.Errorf("invalid TLS file: %w", err)
+		} else if !fi.Mode().IsRegular() {
+			return fmt.Errorf("invalid TLS file: %s is not a regular file", p)
+		}
+	}
+	return nil
+}
+
+func (t TLSConfig) hasFiles() bool {
+	return t.SSLCA != "" || t.SSLCert != ""
+}
+
+// PodTLS is the TLS configuration with the paths of the files in the pod. The paths of files not given are empty.
+type PodTLS struct {
+	Mode string
+	CA   string
+	Cert string
+	Key  string
+	// CertKey is the client certificate followed by the key, for clients that take them in a single file
+	CertKey string
+}
+
+func (t TLSConfig) podTLS() PodTLS {
+	p := PodTLS{Mode: t.SSLMode}
+	if t.SSLCA != "" {
+		p.CA = fmt.Sprintf("%s/%s", tlsMountPath, tlsCAKey)
+	}
+	if t.SSLCert != "" {
+		p.Cert = fmt.Sprintf("%s/%s", tlsMountPath, tlsCertKey)
+		p.Key = fmt.Sprintf("%s/%s", tlsMountPath, tlsKeyKey)
+		p.CertKey = fmt.Sprintf("%s/%s", tlsMountPath, tlsCertKeyKey)
+	}
+	return p
+}
+
+// TLSConfigurer is implemented by DBCommanders that translate the TLS options into the options of the client.
+type TLSConfigurer interface {
+	ConfigureTLS(tls PodTLS) error
+}
+
+func configureTLS(conf *Config, dbCommander DBCommander) error {
+	if conf.TLS == (TLSConfig{}) {
+		return nil
+	}
+	c, ok := dbCommander.(TLSConfigurer)
+	if !ok {
+		return fmt.Errorf("%s does not support the TLS options", dbCommander.CommandType().CommandName())
+	}
+	return c.ConfigureTLS(conf.TLS.podTLS())
+}
+
+func tlsSecretName(podName string) string {
+	return fmt.Sprintf("%s-tls", podName)
+}
+
+func createTLSSecretSpec(secretName, namespace string, t TLSConfig, pod *corev1.Pod) (*corev1.Secret, error) {
+	data := map[string][]byte{}
+	for key, p := range map[string]string{tlsCAKey: t.SSLCA, tlsCertKey: t.SSLCert, tlsKeyKey: t.SSLKey} {
+		if p == "" {
+			continue
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS file: %w", err)
+		}
+		data[key] = b
+	}
+	if t.SSLCert != "" {
+		data[tlsCertKeyKey] = slices.Concat(data[tlsCertKey], []byte("\n"), data[tlsKeyKey])
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(pod, corev1.SchemeGroupVersion.WithKind("Pod")),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}, nil
+}
+
+// createTLSSecret creates the Secret holding the certificate files, if any.
+func createTLSSecret(clientset kubernetes.Interface, conf *Config, pod *corev1.Pod) error {
+	if !conf.TLS.hasFiles() {
+		return nil
+	}
+	secret, err := createTLSSecretSpec(tlsSecretName(pod.Name), conf.Namespace, conf.TLS, pod)
+	if err != nil {
+		return err
+	}
+	if _, err := clientset.CoreV1().Secrets(conf.Namespace).Create(context.Background(), secret, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create TLS secret: %w", err)
+	}
+	return nil
+}
+
+// mountTLSSecret mounts the Secret holding the certificate files at /podsql-tls.
+func mountTLSSecret(pod *corev1.Pod, t TLSConfig) {
+	if !t.hasFiles() {
+		return
+	}
+	// libpq refuses keys readable by others
+	mode := int32(0440)
+	volume := corev1.Volume{
+		Name: "tls-volume",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: tlsSecretName(pod.Name), DefaultMode: &mode},
+		},
+	}
+	mount := corev1.VolumeMount{Name: volume.Name, MountPath: tlsMountPath, ReadOnly: true}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, volume)
+	for i := range pod.Spec.Containers {
+		pod.Spec.Containers[i].VolumeMounts = append(pod.Spec.Containers[i].VolumeMounts, mount)
+	}
+}
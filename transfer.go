@@ -0,0 +1,253 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// The data of a ConfigMap is limited to 1MiB, including its metadata.
+// Queries larger than this are streamed into the running pod instead.
+const maxConfigMapDataSize = 1000 * 1024
+
+// configMapDataSize returns the size of the keys and values of the data of a ConfigMap.
+func configMapDataSize(data map[string]string) int {
+	size := 0
+	for k, v := range data {
+		size += len(k) + len(v)
+	}
+	return size
+}
+
+const (
+	// podsqlReadyFile is created in the container once every file has been transferred.
+	podsqlReadyFile = "/tmp/.podsql-ready"
+	// podsqlExitFile holds the exit code of the command while the container is kept alive.
+	podsqlExitFile = "/tmp/.podsql-exit"
+	// podsqlReleaseFile is created in the container when the kept alive container may terminate.
+	podsqlReleaseFile = "/tmp/.podsql-release"
+)
+
+// copyInDir is the directory in the container where files given with a relative path to --copy-in are placed.
+const copyInDir = "/data"
+
+// podFile is a file to be transferred into the container.
+// The content is read from LocalPath if it is set, otherwise Data is used.
+type podFile struct {
+	Path      string
+	Data      []byte
+	LocalPath string
+}
+
+func copyInFiles(mappings []FileMapping) []podFile {
+	files := []podFile{}
+	for _, mapping := range mappings {
+		files = append(files, podFile{Path: mapping.Remote, LocalPath: mapping.Local})
+	}
+	return files
+}
+
+// waitForReadyCommand makes the command wait until the files have been transferred into the container.
+func waitForReadyCommand(command string) string {
+	return fmt.Sprintf("until [ -f %s ]; do sleep 1; done; %s", podsqlReadyFile, command)
+}
+
+// holdAfterCommand keeps the container alive after the command finishes until podsqlReleaseFile is created,
+// so that files can still be copied from it.
+func holdAfterCommand(command string) string {
+	return fmt.Sprintf("%s; echo $? > %s; until [ -f %s ]; do sleep 1; done; exit $(cat %s)",
+		command, podsqlExitFile, podsqlReleaseFile, podsqlExitFile)
+}
+
+// waitForCommandExit waits until the command wrapped by holdAfterCommand finishes.
+func waitForCommandExit(ctx context.Context, clientset kubernetes.Interface, config *rest.Config, namespace, podName, container string) error {
+	return wait.PollUntilContextCancel(ctx, 1*time.Second, true, func(ctx context.Context) (bool, error) {
+		pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+		if err != nil {
+			return false, fmt.Errorf("failed to get pod: %w", err)
+		}
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			return false, fmt.Errorf("pod terminated before files were copied")
+		}
+		err = execInPod(ctx, clientset, config, namespace, podName, container, []string{"test", "-f", podsqlExitFile}, nil, nil, nil)
+		return err == nil, nil
+	})
+}
+
+func releaseContainer(ctx context.Context, clientset kubernetes.Interface, config *rest.Config, namespace, podName, container string) error {
+	if err := execInPod(ctx, clientset, config, namespace, podName, container, []string{"touch", podsqlReleaseFile}, nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to release container: %w", err)
+	}
+	return nil
+}
+
+func execInPod(ctx context.Context, clientset kubernetes.Interface, config *rest.Config, namespace, podName, container string, command []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	req := clientset.CoreV1().RESTClient().
+		Post().
+		Resource("pods").
+		Name(podName).
+		Namespace(namespace).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdin:     stdin != nil,
+			Stdout:    stdout != nil,
+			Stderr:    stderr != nil,
+			TTY:       false,
+		}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+	if err != nil {
+		return fmt.Errorf("failed to create executor: %w", err)
+	}
+
+	return exec.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+}
+
+// copyFilesToPod streams the files into the container as a tar archive, in the same way as `kubectl cp`.
+// The container image must provide tar.
+func copyFilesToPod(ctx context.Context, clientset kubernetes.Interface, config *rest.Config, namespace, podName, container string, files []podFile) error {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeTar(writer, files))
+	}()
+
+	var stderr bytes.Buffer
+	if err := execInPod(ctx, clientset, config, namespace, podName, container, []string{"tar", "xmf", "-", "-C", "/"}, reader, nil, &stderr); err != nil {
+		return fmt.Errorf("failed to copy files to pod: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// copyFileFromPod copies a file or a directory in the container to the local machine, in the same way as `kubectl cp`.
+// If local is an existing directory, the file is copied into it.
+func copyFileFromPod(ctx context.Context, clientset kubernetes.Interface, config *rest.Config, namespace, podName, container string, mapping FileMapping) error {
+	remote := path.Clean(mapping.Remote)
+	base := path.Base(remote)
+	local := mapping.Local
+	if fi, err := os.Stat(local); err == nil && fi.IsDir() {
+		local = filepath.Join(local, base)
+	}
+
+	reader, writer := io.Pipe()
+	// stderr is written by the exec until done is closed, so it is only read after that
+	var stderr bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		writer.CloseWithError(execInPod(ctx, clientset, config, namespace, podName, container,
+			[]string{"tar", "cf", "-", "-C", path.Dir(remote), base}, nil, writer, &stderr))
+	}()
+
+	err := readTar(reader, base, local)
+	reader.CloseWithError(err)
+	<-done
+	if err != nil {
+		return fmt.Errorf("failed to copy %s from pod: %w: %s", mapping.Remote, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func readTar(r io.Reader, base, local string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(hdr.Name)
+		if name != base && !strings.HasPrefix(name, base+"/") {
+			return fmt.Errorf("unexpected file in archive: %s", hdr.Name)
+		}
+		dest := filepath.Join(local, filepath.FromSlash(strings.TrimPrefix(name, base)))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dest, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(hdr.Mode).Perm())
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+		default:
+			// Skip links and special files
+		}
+	}
+}
+
+func writeTar(w io.Writer, files []podFile) error {
+	tw := tar.NewWriter(w)
+	for _, f := range files {
+		if err := writeTarFile(tw, f); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func writeTarFile(tw *tar.Writer, f podFile) error {
+	hdr := &tar.Header{
+		Name: strings.TrimPrefix(path.Clean(f.Path), "/"),
+		Mode: 0644,
+		Size: int64(len(f.Data)),
+	}
+	if f.LocalPath == "" {
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(f.Data)
+		return err
+	}
+
+	src, err := os.Open(f.LocalPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	hdr.Size = fi.Size()
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, src)
+	return err
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigMapDataSize(t *testing.T) {
+	query := strings.Repeat("x", maxConfigMapDataSize-100)
+	if configMapDataSize(map[string]string{"query.sql": query}) > maxConfigMapDataSize {
+		t.Fatal("a query under the limit does not fit")
+	}
+	data := map[string]string{"query.sql": query, "sqlcmdini.sql": strings.Repeat("y", 200)}
+	if configMapDataSize(data) <= maxConfigMapDataSize {
+		t.Error("the shipped files are not counted in the size of the ConfigMap")
+	}
+}
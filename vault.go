@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VaultConfig locates the credentials in a HashiCorp Vault KV secrets engine.
+// Path is the API path without /v1/, e.g. secret/data/db/prod for KV version 2 or kv/db/prod for version 1.
+type VaultConfig struct {
+	Address     string `yaml:"address"`
+	Namespace   string `yaml:"namespace"`
+	Path        string `yaml:"path"`
+	UsernameKey string `yaml:"usernameKey"`
+	PasswordKey string `yaml:"passwordKey"`
+}
+
+type vaultCredentialResolver struct {
+	config VaultConfig
+	token  func() (string, error)
+	client *http.Client
+}
+
+// newVaultCredentialResolver takes the address, namespace and token from VAULT_ADDR, VAULT_NAMESPACE and VAULT_TOKEN
+// (or ~/.vault-token written by vault login) unless they are in the config.
+func newVaultCredentialResolver(c VaultConfig) *vaultCredentialResolver {
+	if c.Address == "" {
+		c.Address = os.Getenv("VAULT_ADDR")
+	}
+	if c.Namespace == "" {
+		c.Namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	if c.UsernameKey == "" {
+		c.UsernameKey = "username"
+	}
+	if c.PasswordKey == "" {
+		c.PasswordKey = "password"
+	}
+	return &vaultCredentialResolver{config: c, token: vaultToken, client: http.DefaultClient}
+}
+
+func vaultToken() (string, error) {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(filepath.Join(homeDir, ".vault-token"))
+	if err != nil {
+		return "", fmt.Errorf("no vault token found in VAULT_TOKEN or ~/.vault-token: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (r *vaultCredentialResolver) Resolve(ctx context.Context) (ConnectInfo, error) {
+	if r.config.Address == "" || r.config.Path == "" {
+		return ConnectInfo{}, fmt.Errorf("vault address and path are required")
+	}
+	token, err := r.token()
+	if err != nil {
+		return ConnectInfo{}, err
+	}
+
+	url := fmt.Sprintf("%s/v1/%s", strings.TrimRight(r.config.Address, "/"), strings.TrimLeft(r.config.Path, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return ConnectInfo{}, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if r.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", r.config.Namespace)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return ConnectInfo{}, fmt.Errorf("failed to read vault secret: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ConnectInfo{}, fmt.Errorf("failed to read vault secret: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ConnectInfo{}, fmt.Errorf("failed to read vault secret %s: %s: %s", r.config.Path, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var secret struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return ConnectInfo{}, fmt.Errorf("invalid vault response: %w", err)
+	}
+	data := secret.Data
+	// KV version 2 nests the secret in data.data with its metadata
+	if nested, ok := data["data"].(map[string]any); ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = nested
+		}
+	}
+
+	password, _ := data[r.config.PasswordKey].(string)
+	if password == "" {
+		return ConnectInfo{}, fmt.Errorf("vault secret %s has no %s", r.config.Path, r.config.PasswordKey)
+	}
+	user, _ := data[r.config.UsernameKey].(string)
+	return ConnectInfo{User: user, Password: password}, nil
+}